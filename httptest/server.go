@@ -0,0 +1,236 @@
+package astihttptest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Server represents a scriptable fake HTTP server that records received requests
+type Server struct {
+	mr     *sync.Mutex // Locks rs
+	mrs    *sync.Mutex // Locks routes
+	o      ServerOptions
+	routes map[string]*route
+	rs     []RecordedRequest
+	s      *httptest.Server
+}
+
+// ServerOptions represents server options
+type ServerOptions struct {
+	// Latency is added before every response unless the response has its own latency
+	Latency time.Duration
+	// NotFound is the response sent when no route matches. Defaults to a 404 with an empty body.
+	NotFound *Response
+}
+
+// Response represents a canned response
+type Response struct {
+	Body    []byte
+	Header  http.Header
+	Latency time.Duration
+	// If Ranges is true, the body is served with byte-range support and StatusCode is ignored unless >= 400
+	Ranges     bool
+	StatusCode int
+}
+
+// RecordedRequest represents a request received by the server
+type RecordedRequest struct {
+	Body       []byte
+	Header     http.Header
+	Method     string
+	Path       string
+	Query      string
+	ReceivedAt time.Time
+}
+
+type route struct {
+	count int
+	rs    []Response
+}
+
+// NewServer creates and starts a new server
+func NewServer(o ServerOptions) (s *Server) {
+	s = &Server{
+		mr:     &sync.Mutex{},
+		mrs:    &sync.Mutex{},
+		o:      o,
+		routes: make(map[string]*route),
+	}
+	s.s = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+// Handle adds a route. Responses are sent in order and the last one is repeated once the others have been consumed.
+// An empty method matches all methods.
+func (s *Server) Handle(method, path string, rs ...Response) {
+	s.mrs.Lock()
+	defer s.mrs.Unlock()
+	s.routes[routeKey(method, path)] = &route{rs: rs}
+}
+
+// HandleStatuses adds a route that sends the same body with a sequence of status codes such as "500, 500, 200"
+func (s *Server) HandleStatuses(method, path string, body []byte, codes ...int) {
+	var rs []Response
+	for _, c := range codes {
+		rs = append(rs, Response{
+			Body:       body,
+			StatusCode: c,
+		})
+	}
+	s.Handle(method, path, rs...)
+}
+
+// HandleRanges adds a route that serves the body with byte-range support
+func (s *Server) HandleRanges(method, path string, body []byte) {
+	s.Handle(method, path, Response{
+		Body:   body,
+		Ranges: true,
+	})
+}
+
+// URL returns the server base URL
+func (s *Server) URL() string {
+	return s.s.URL
+}
+
+// Client returns an *http.Client configured to talk to the server
+func (s *Server) Client() *http.Client {
+	return s.s.Client()
+}
+
+// Close closes the server
+func (s *Server) Close() {
+	s.s.Close()
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []RecordedRequest {
+	s.mr.Lock()
+	defer s.mr.Unlock()
+	return append([]RecordedRequest(nil), s.rs...)
+}
+
+// RequestsFor returns the requests received so far for a specific method and path
+// An empty method matches all methods.
+func (s *Server) RequestsFor(method, path string) (rs []RecordedRequest) {
+	s.mr.Lock()
+	defer s.mr.Unlock()
+	for _, r := range s.rs {
+		if r.Path == path && (method == "" || r.Method == method) {
+			rs = append(rs, r)
+		}
+	}
+	return
+}
+
+// Reset removes all routes and recorded requests
+func (s *Server) Reset() {
+	s.mr.Lock()
+	s.rs = []RecordedRequest{}
+	s.mr.Unlock()
+	s.mrs.Lock()
+	s.routes = make(map[string]*route)
+	s.mrs.Unlock()
+}
+
+func (s *Server) nextResponse(method, path string) (r Response, ok bool) {
+	// Lock
+	s.mrs.Lock()
+	defer s.mrs.Unlock()
+
+	// Get route
+	var rt *route
+	if rt, ok = s.routes[routeKey(method, path)]; !ok {
+		if rt, ok = s.routes[routeKey("", path)]; !ok {
+			return
+		}
+	}
+
+	// No responses
+	if len(rt.rs) == 0 {
+		r = Response{StatusCode: http.StatusOK}
+		return
+	}
+
+	// Get response
+	idx := rt.count
+	if idx >= len(rt.rs) {
+		idx = len(rt.rs) - 1
+	}
+	r = rt.rs[idx]
+	rt.count++
+	return
+}
+
+func (s *Server) serveHTTP(rw http.ResponseWriter, r *http.Request) {
+	// Read body
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "astihttptest: reading body failed: %s", err)
+		return
+	}
+
+	// Record request
+	s.mr.Lock()
+	s.rs = append(s.rs, RecordedRequest{
+		Body:       b,
+		Header:     r.Header.Clone(),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		ReceivedAt: time.Now(),
+	})
+	s.mr.Unlock()
+
+	// Get response
+	resp, ok := s.nextResponse(r.Method, r.URL.Path)
+	if !ok {
+		resp = Response{StatusCode: http.StatusNotFound}
+		if s.o.NotFound != nil {
+			resp = *s.o.NotFound
+		}
+	}
+
+	// Latency
+	l := s.o.Latency
+	if resp.Latency > 0 {
+		l = resp.Latency
+	}
+	if l > 0 {
+		select {
+		case <-time.After(l):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	// Add headers
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			rw.Header().Add(k, v)
+		}
+	}
+
+	// Byte ranges
+	if resp.Ranges && resp.StatusCode < http.StatusBadRequest {
+		http.ServeContent(rw, r, "", time.Time{}, bytes.NewReader(resp.Body))
+		return
+	}
+
+	// Write
+	if resp.StatusCode == 0 {
+		resp.StatusCode = http.StatusOK
+	}
+	rw.WriteHeader(resp.StatusCode)
+	rw.Write(resp.Body)
+}
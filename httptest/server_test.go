@@ -0,0 +1,64 @@
+package astihttptest_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/asticode/go-astitools/http"
+	"github.com/asticode/go-astitools/httptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	// Init
+	s := astihttptest.NewServer(astihttptest.ServerOptions{})
+	defer s.Close()
+	s.HandleStatuses(http.MethodGet, "/retry", []byte("ok"), 500, 500, 200)
+	s.HandleRanges(http.MethodGet, "/range", []byte("0123456789"))
+
+	// Failure sequence
+	snd := astihttp.NewSender(astihttp.SenderOptions{RetryMax: 2})
+	req, _ := http.NewRequest(http.MethodGet, s.URL()+"/retry", nil)
+	resp, err := snd.Send(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok", string(b))
+	assert.Len(t, s.RequestsFor(http.MethodGet, "/retry"), 3)
+
+	// Byte range
+	req, _ = http.NewRequest(http.MethodGet, s.URL()+"/range", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	b, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "234", string(b))
+
+	// Recorder
+	resp, err = http.Post(s.URL()+"/unknown?k=v", "text/plain", strings.NewReader("body"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	rs := s.RequestsFor(http.MethodPost, "/unknown")
+	assert.Len(t, rs, 1)
+	assert.Equal(t, "body", string(rs[0].Body))
+	assert.Equal(t, "k=v", rs[0].Query)
+	assert.Len(t, s.Requests(), 5)
+
+	// Downloader
+	s.Reset()
+	s.Handle(http.MethodGet, "/1", astihttptest.Response{Body: []byte("1")})
+	s.Handle(http.MethodGet, "/2", astihttptest.Response{Body: []byte("2")})
+	buf := &bytes.Buffer{}
+	d := astihttp.NewDownloader(astihttp.DownloaderOptions{NumberOfWorkers: 2})
+	err = d.DownloadInWriter(context.Background(), buf, s.URL()+"/1", s.URL()+"/2")
+	assert.NoError(t, err)
+	assert.Equal(t, "12", buf.String())
+}
@@ -0,0 +1,264 @@
+package astipcm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Sample encodings
+const (
+	SampleEncodingFloat    = "float"
+	SampleEncodingSigned   = "signed"
+	SampleEncodingUnsigned = "unsigned"
+)
+
+// SampleFormat represents the way samples are stored in an interleaved PCM byte stream
+// Decoded samples are always signed and have a bit depth equal to BitDepth: unsigned samples are centered around 0
+// and float samples, which are expected between -1 and 1, are scaled to the 32 bits signed range.
+type SampleFormat struct {
+	BigEndian bool
+	// BitDepth is either 8, 16, 24 or 32. Float samples only support 32.
+	BitDepth int
+	// Encoding defaults to SampleEncodingSigned
+	Encoding string
+}
+
+// BytesPerSample returns the number of bytes a sample is stored on
+func (f SampleFormat) BytesPerSample() int {
+	return f.BitDepth / 8
+}
+
+// Validate checks whether the format is supported
+func (f SampleFormat) Validate() error {
+	switch f.Encoding {
+	case SampleEncodingFloat:
+		if f.BitDepth != 32 {
+			return fmt.Errorf("astipcm: invalid float bit depth %d", f.BitDepth)
+		}
+	case "", SampleEncodingSigned, SampleEncodingUnsigned:
+		if f.BitDepth != 8 && f.BitDepth != 16 && f.BitDepth != 24 && f.BitDepth != 32 {
+			return fmt.Errorf("astipcm: invalid bit depth %d", f.BitDepth)
+		}
+	default:
+		return fmt.Errorf("astipcm: invalid encoding %s", f.Encoding)
+	}
+	return nil
+}
+
+func (f SampleFormat) byteOrder() binary.ByteOrder {
+	if f.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// Decode decodes a sample stored in b which must be BytesPerSample() long
+// Assumes the format is valid
+func (f SampleFormat) Decode(b []byte) (s int) {
+	// Read unsigned value
+	var u uint32
+	switch f.BitDepth {
+	case 8:
+		u = uint32(b[0])
+	case 16:
+		u = uint32(f.byteOrder().Uint16(b))
+	case 24:
+		if f.BigEndian {
+			u = uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		} else {
+			u = uint32(b[2])<<16 | uint32(b[1])<<8 | uint32(b[0])
+		}
+	case 32:
+		u = f.byteOrder().Uint32(b)
+	}
+
+	// Convert
+	switch f.Encoding {
+	case SampleEncodingFloat:
+		v := math.Max(-1, math.Min(1, float64(math.Float32frombits(u))))
+		s = int(math.Round(v * float64(maxSample(32))))
+	case SampleEncodingUnsigned:
+		s = int(u) - 1<<uint(f.BitDepth-1)
+	default:
+		// Extend sign
+		s = int(u)
+		if u&(1<<uint(f.BitDepth-1)) > 0 {
+			s -= 1 << uint(f.BitDepth)
+		}
+	}
+	return
+}
+
+// Encode encodes a sample in b which must be BytesPerSample() long
+// Samples out of range are clipped
+// Assumes the format is valid
+func (f SampleFormat) Encode(s int, b []byte) {
+	// Clip
+	max := maxSample(f.BitDepth)
+	if s > max {
+		s = max
+	} else if s < -max-1 {
+		s = -max - 1
+	}
+
+	// Convert
+	var u uint32
+	switch f.Encoding {
+	case SampleEncodingFloat:
+		u = math.Float32bits(float32(float64(s) / float64(max)))
+	case SampleEncodingUnsigned:
+		u = uint32(s + 1<<uint(f.BitDepth-1))
+	default:
+		u = uint32(s)
+	}
+
+	// Write unsigned value
+	switch f.BitDepth {
+	case 8:
+		b[0] = uint8(u)
+	case 16:
+		f.byteOrder().PutUint16(b, uint16(u))
+	case 24:
+		if f.BigEndian {
+			b[0], b[1], b[2] = uint8(u>>16), uint8(u>>8), uint8(u)
+		} else {
+			b[0], b[1], b[2] = uint8(u), uint8(u>>8), uint8(u>>16)
+		}
+	case 32:
+		f.byteOrder().PutUint32(b, u)
+	}
+}
+
+// Decoder reads samples from an interleaved PCM byte stream
+type Decoder struct {
+	b []byte
+	f SampleFormat
+	r *bufio.Reader
+}
+
+// NewDecoder creates a new decoder reading from r
+func NewDecoder(r io.Reader, f SampleFormat) (d *Decoder, err error) {
+	// Validate format
+	if err = f.Validate(); err != nil {
+		return
+	}
+
+	// Create decoder
+	d = &Decoder{
+		b: make([]byte, f.BytesPerSample()),
+		f: f,
+		r: bufio.NewReader(r),
+	}
+	return
+}
+
+// Format returns the decoder format
+func (d *Decoder) Format() SampleFormat {
+	return d.f
+}
+
+// ReadSample reads one sample
+// It returns io.EOF once the stream has been fully read and io.ErrUnexpectedEOF if the stream ends in the middle of a
+// sample
+func (d *Decoder) ReadSample() (s int, err error) {
+	if _, err = io.ReadFull(d.r, d.b); err != nil {
+		return
+	}
+	s = d.f.Decode(d.b)
+	return
+}
+
+// ReadSamples reads up to len(ss) samples and returns the number of samples read
+func (d *Decoder) ReadSamples(ss []int) (n int, err error) {
+	for n < len(ss) {
+		if ss[n], err = d.ReadSample(); err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
+
+// Forward reads samples until the end of the stream and forwards them to fn
+// It can be plugged into converters by providing their Add method
+func (d *Decoder) Forward(fn SampleFunc) (err error) {
+	for {
+		// Read sample
+		var s int
+		if s, err = d.ReadSample(); err != nil {
+			if err == io.EOF {
+				err = nil
+			} else {
+				err = errors.Wrap(err, "astipcm: reading sample failed")
+			}
+			return
+		}
+
+		// Custom
+		if err = fn(s); err != nil {
+			err = errors.Wrap(err, "astipcm: handling sample failed")
+			return
+		}
+	}
+}
+
+// Encoder writes samples to an interleaved PCM byte stream
+type Encoder struct {
+	b []byte
+	f SampleFormat
+	w io.Writer
+}
+
+// NewEncoder creates a new encoder writing to w
+func NewEncoder(w io.Writer, f SampleFormat) (e *Encoder, err error) {
+	// Validate format
+	if err = f.Validate(); err != nil {
+		return
+	}
+
+	// Create encoder
+	e = &Encoder{
+		b: make([]byte, f.BytesPerSample()),
+		f: f,
+		w: w,
+	}
+	return
+}
+
+// Format returns the encoder format
+func (e *Encoder) Format() SampleFormat {
+	return e.f
+}
+
+// WriteSample writes one sample
+// It is a SampleFunc and can therefore be used as the output of converters
+func (e *Encoder) WriteSample(s int) (err error) {
+	e.f.Encode(s, e.b)
+	if _, err = e.w.Write(e.b); err != nil {
+		err = errors.Wrap(err, "astipcm: writing sample failed")
+		return
+	}
+	return
+}
+
+// WriteSamples writes samples in one write
+func (e *Encoder) WriteSamples(ss []int) (err error) {
+	// Encode
+	bps := e.f.BytesPerSample()
+	b := make([]byte, len(ss)*bps)
+	for idx, s := range ss {
+		e.f.Encode(s, b[idx*bps:(idx+1)*bps])
+	}
+
+	// Write
+	if _, err = e.w.Write(b); err != nil {
+		err = errors.Wrap(err, "astipcm: writing samples failed")
+		return
+	}
+	return
+}
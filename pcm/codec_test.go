@@ -0,0 +1,81 @@
+package astipcm
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleFormat(t *testing.T) {
+	// Invalid
+	assert.Error(t, SampleFormat{BitDepth: 12}.Validate())
+	assert.Error(t, SampleFormat{BitDepth: 16, Encoding: SampleEncodingFloat}.Validate())
+	assert.Error(t, SampleFormat{BitDepth: 16, Encoding: "invalid"}.Validate())
+
+	// Loop through formats
+	for _, v := range []struct {
+		b  []byte
+		f  SampleFormat
+		ss []int
+	}{
+		{b: []byte{0x00, 0x80, 0xff, 0x7f}, f: SampleFormat{BitDepth: 8, Encoding: SampleEncodingUnsigned}, ss: []int{-128, 0, 127, -1}},
+		{b: []byte{0x80, 0x7f, 0xff}, f: SampleFormat{BitDepth: 8}, ss: []int{-128, 127, -1}},
+		{b: []byte{0x01, 0x80, 0xff, 0xff}, f: SampleFormat{BitDepth: 16}, ss: []int{-32767, -1}},
+		{b: []byte{0x80, 0x01, 0x00, 0x02}, f: SampleFormat{BigEndian: true, BitDepth: 16}, ss: []int{-32767, 2}},
+		{b: []byte{0x00, 0x00, 0x80, 0x01, 0x02, 0x03}, f: SampleFormat{BitDepth: 24}, ss: []int{-8388608, 197121}},
+		{b: []byte{0xff, 0xff, 0xfe, 0x01, 0x02, 0x03}, f: SampleFormat{BigEndian: true, BitDepth: 24}, ss: []int{-2, 66051}},
+		{b: []byte{0x00, 0x00, 0x00, 0x80, 0xff, 0xff, 0xff, 0x7f}, f: SampleFormat{BitDepth: 32}, ss: []int{-2147483648, 2147483647}},
+		{b: []byte{0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff}, f: SampleFormat{BigEndian: true, BitDepth: 32, Encoding: SampleEncodingUnsigned}, ss: []int{-2147483648, 2147483647}},
+		{b: []byte{0x00, 0x00, 0x80, 0x3f, 0x00, 0x00, 0x80, 0xbf, 0x00, 0x00, 0x00, 0x00}, f: SampleFormat{BitDepth: 32, Encoding: SampleEncodingFloat}, ss: []int{2147483647, -2147483647, 0}},
+	} {
+		// Decode
+		d, err := NewDecoder(bytes.NewReader(v.b), v.f)
+		assert.NoError(t, err)
+		ss := make([]int, len(v.ss)+1)
+		n, err := d.ReadSamples(ss)
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, v.ss, ss[:n])
+
+		// Encode
+		w := &bytes.Buffer{}
+		e, err := NewEncoder(w, v.f)
+		assert.NoError(t, err)
+		err = e.WriteSamples(v.ss)
+		assert.NoError(t, err)
+		assert.Equal(t, v.b, w.Bytes())
+	}
+
+	// Clipping
+	w := &bytes.Buffer{}
+	e, err := NewEncoder(w, SampleFormat{BitDepth: 8})
+	assert.NoError(t, err)
+	assert.NoError(t, e.WriteSample(200))
+	assert.NoError(t, e.WriteSample(-200))
+	assert.Equal(t, []byte{0x7f, 0x80}, w.Bytes())
+
+	// Unexpected EOF
+	d, err := NewDecoder(bytes.NewReader([]byte{0x01, 0x02, 0x03}), SampleFormat{BitDepth: 16})
+	assert.NoError(t, err)
+	_, err = d.ReadSample()
+	assert.NoError(t, err)
+	_, err = d.ReadSample()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDecoder_Forward(t *testing.T) {
+	// Decode 16 bits stereo, convert to 8 bits mono and encode
+	d, err := NewDecoder(bytes.NewReader([]byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04}), SampleFormat{BitDepth: 16})
+	assert.NoError(t, err)
+	w := &bytes.Buffer{}
+	e, err := NewEncoder(w, SampleFormat{BitDepth: 8, Encoding: SampleEncodingUnsigned})
+	assert.NoError(t, err)
+	c := NewChannelsConverter(2, 1, func(s int) error {
+		s, _ = ConvertBitDepth(s, 16, 8)
+		return e.WriteSample(s)
+	})
+	err = d.Forward(c.Add)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0x83}, w.Bytes())
+}
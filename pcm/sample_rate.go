@@ -2,16 +2,28 @@ package astipcm
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/pkg/errors"
 )
 
 type SampleFunc func(s int) error
 
+// Resampling modes
+const (
+	// Samples are interpolated linearly
+	ResamplingModeLinear = "linear"
+	// Samples are dropped, averaged or repeated. It is fast but creates aliasing.
+	ResamplingModeSimple = "simple"
+	// Samples are interpolated with a windowed sinc, which is band-limited
+	ResamplingModeSinc = "sinc"
+)
+
 type SampleRateConverter struct {
 	b                    [][]int
 	dstSampleRate        int
 	fn                   SampleFunc
+	i                    *sampleRateInterpolator
 	numChannels          int
 	numChannelsProcessed int
 	numSamplesOutputed   int
@@ -19,14 +31,72 @@ type SampleRateConverter struct {
 	srcSampleRate        int
 }
 
+// SampleRateConverterOptions represents sample rate converter options
+type SampleRateConverterOptions struct {
+	DstSampleRate int
+	// Mode defaults to ResamplingModeSimple
+	Mode        string
+	NumChannels int
+	SampleFunc  SampleFunc
+	// SincZeroCrossings is the number of zero crossings on each side of the sinc kernel. The higher, the sharper the
+	// low-pass filter but the slower the conversion. Defaults to 16.
+	SincZeroCrossings int
+	SrcSampleRate     int
+}
+
 func NewSampleRateConverter(srcSampleRate, dstSampleRate, numChannels int, fn SampleFunc) *SampleRateConverter {
-	return &SampleRateConverter{
-		b:             make([][]int, numChannels),
-		dstSampleRate: dstSampleRate,
-		fn:            fn,
-		numChannels:   numChannels,
-		srcSampleRate: srcSampleRate,
+	return newSampleRateConverter(SampleRateConverterOptions{
+		DstSampleRate: dstSampleRate,
+		NumChannels:   numChannels,
+		SampleFunc:    fn,
+		SrcSampleRate: srcSampleRate,
+	})
+}
+
+// NewSampleRateConverterWithOptions creates a new sample rate converter with options
+// Sample rates and number of channels must be strictly positive
+func NewSampleRateConverterWithOptions(o SampleRateConverterOptions) (c *SampleRateConverter, err error) {
+	// Validate options
+	switch o.Mode {
+	case "", ResamplingModeLinear, ResamplingModeSimple, ResamplingModeSinc:
+	default:
+		err = fmt.Errorf("astipcm: invalid resampling mode %s", o.Mode)
+		return
+	}
+	if o.SrcSampleRate <= 0 {
+		err = fmt.Errorf("astipcm: invalid source sample rate %d", o.SrcSampleRate)
+		return
+	}
+	if o.DstSampleRate <= 0 {
+		err = fmt.Errorf("astipcm: invalid destination sample rate %d", o.DstSampleRate)
+		return
+	}
+	if o.NumChannels <= 0 {
+		err = fmt.Errorf("astipcm: invalid number of channels %d", o.NumChannels)
+		return
 	}
+	if o.SincZeroCrossings < 0 {
+		err = fmt.Errorf("astipcm: invalid number of sinc zero crossings %d", o.SincZeroCrossings)
+		return
+	}
+
+	// Create converter
+	c = newSampleRateConverter(o)
+	return
+}
+
+func newSampleRateConverter(o SampleRateConverterOptions) (c *SampleRateConverter) {
+	c = &SampleRateConverter{
+		b:             make([][]int, o.NumChannels),
+		dstSampleRate: o.DstSampleRate,
+		fn:            o.SampleFunc,
+		numChannels:   o.NumChannels,
+		srcSampleRate: o.SrcSampleRate,
+	}
+	if o.SrcSampleRate != o.DstSampleRate && (o.Mode == ResamplingModeLinear || o.Mode == ResamplingModeSinc) {
+		c.i = newSampleRateInterpolator(o)
+	}
+	return
 }
 
 func (c *SampleRateConverter) Reset() {
@@ -34,6 +104,18 @@ func (c *SampleRateConverter) Reset() {
 	c.numChannelsProcessed = 0
 	c.numSamplesOutputed = 0
 	c.numSamplesProcessed = 0
+	if c.i != nil {
+		c.i.reset()
+	}
+}
+
+// Flush outputs the samples that are waiting for future samples to be interpolated, as if the stream ended
+// It is a no-op in simple mode
+func (c *SampleRateConverter) Flush() (err error) {
+	if c.i != nil {
+		return c.i.process(true)
+	}
+	return
 }
 
 func (c *SampleRateConverter) Add(i int) (err error) {
@@ -46,6 +128,11 @@ func (c *SampleRateConverter) Add(i int) (err error) {
 		return
 	}
 
+	// Interpolate
+	if c.i != nil {
+		return c.i.add(i)
+	}
+
 	// Increment num channels processed
 	c.numChannelsProcessed++
 
@@ -124,3 +211,211 @@ func (c *SampleRateConverter) Add(i int) (err error) {
 	c.b = make([][]int, c.numChannels)
 	return
 }
+
+// Beyond that number of phases, sinc coefficients are not cached
+const sampleRateInterpolatorMaxCachedPhases = 4096
+
+// sampleRateInterpolator converts sample rates by interpolating output samples from the input samples surrounding them
+// The ratio is reduced to dst/src = l/m so that the position of output samples in the input stream can be tracked
+// exactly with an integer index and a phase in [0, l).
+type sampleRateInterpolator struct {
+	b           [][]float64 // Buffered frames per channel
+	bOffset     int         // Index of the first buffered frame
+	cs          map[int][]float64
+	fc          float64
+	fn          SampleFunc
+	frame       []int
+	l           int
+	m           int
+	mode        string
+	numChannels int
+	numFrames   int
+	outIdx      int
+	outPhase    int
+	w           int // Number of frames needed on each side of an output sample
+}
+
+func newSampleRateInterpolator(o SampleRateConverterOptions) (i *sampleRateInterpolator) {
+	// Reduce ratio
+	g := gcd(o.SrcSampleRate, o.DstSampleRate)
+
+	// Create interpolator
+	i = &sampleRateInterpolator{
+		cs:          make(map[int][]float64),
+		fn:          o.SampleFunc,
+		l:           o.DstSampleRate / g,
+		m:           o.SrcSampleRate / g,
+		mode:        o.Mode,
+		numChannels: o.NumChannels,
+		w:           1,
+	}
+
+	// Sinc
+	if i.mode == ResamplingModeSinc {
+		// Default zero crossings
+		if o.SincZeroCrossings <= 0 {
+			o.SincZeroCrossings = 16
+		}
+
+		// Cutoff frequency is relative to the src Nyquist frequency and must be lowered when downsampling to avoid
+		// aliasing, which widens the kernel
+		i.fc = math.Min(1, float64(i.l)/float64(i.m))
+		i.w = int(math.Ceil(float64(o.SincZeroCrossings) / i.fc))
+	}
+
+	// Reset
+	i.reset()
+	return
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func (i *sampleRateInterpolator) reset() {
+	i.b = make([][]float64, i.numChannels)
+	i.bOffset = 0
+	i.frame = []int{}
+	i.numFrames = 0
+	i.outIdx = 0
+	i.outPhase = 0
+}
+
+func (i *sampleRateInterpolator) add(s int) (err error) {
+	// Do nothing until we have all channels
+	i.frame = append(i.frame, s)
+	if len(i.frame) < i.numChannels {
+		return
+	}
+
+	// Append frame to buffer
+	for idx, v := range i.frame {
+		i.b[idx] = append(i.b[idx], float64(v))
+	}
+	i.frame = i.frame[:0]
+	i.numFrames++
+
+	// Process
+	return i.process(false)
+}
+
+// process outputs samples whose surrounding frames have been received. When flushing, missing frames are replaced
+// with the last frame in linear mode and with silence in sinc mode.
+func (i *sampleRateInterpolator) process(flush bool) (err error) {
+	// Loop through output samples
+	for (flush && i.outIdx < i.numFrames) || (!flush && i.outIdx+i.w < i.numFrames) {
+		// Loop through channels
+		for idx := range i.b {
+			// Interpolate
+			var v float64
+			if i.mode == ResamplingModeSinc {
+				v = i.sinc(idx)
+			} else {
+				v = i.linear(idx)
+			}
+
+			// Custom
+			if err = i.fn(int(math.Round(v))); err != nil {
+				err = errors.Wrap(err, "astipcm: handling sample failed")
+				return
+			}
+		}
+
+		// Move to next output sample
+		i.outPhase += i.m
+		i.outIdx += i.outPhase / i.l
+		i.outPhase %= i.l
+	}
+
+	// Remove frames that won't be needed anymore
+	if len(i.b) > 0 {
+		n := i.outIdx - i.w + 1 - i.bOffset
+		if n > len(i.b[0]) {
+			n = len(i.b[0])
+		}
+		if n > 0 {
+			for idx := range i.b {
+				i.b[idx] = i.b[idx][n:]
+			}
+			i.bOffset += n
+		}
+	}
+	return
+}
+
+// frameValue returns the value of a channel in a frame, frames out of the buffer being silent
+func (i *sampleRateInterpolator) frameValue(channel, frame int) float64 {
+	if idx := frame - i.bOffset; idx >= 0 && idx < len(i.b[channel]) {
+		return i.b[channel][idx]
+	}
+	return 0
+}
+
+func (i *sampleRateInterpolator) linear(channel int) float64 {
+	// Get frames
+	x0 := i.frameValue(channel, i.outIdx)
+	x1 := x0
+	if i.outIdx+1 < i.numFrames {
+		x1 = i.frameValue(channel, i.outIdx+1)
+	}
+
+	// Interpolate
+	return x0 + (x1-x0)*float64(i.outPhase)/float64(i.l)
+}
+
+func (i *sampleRateInterpolator) sinc(channel int) (v float64) {
+	cs := i.coefficients(i.outPhase)
+	for j, c := range cs {
+		v += c * i.frameValue(channel, i.outIdx-i.w+1+j)
+	}
+	return
+}
+
+// coefficients returns the Blackman windowed sinc coefficients applied to the frames surrounding an output sample
+// with the provided phase. They are normalized so that the DC gain is 1.
+func (i *sampleRateInterpolator) coefficients(phase int) (cs []float64) {
+	// Cached
+	var ok bool
+	if cs, ok = i.cs[phase]; ok {
+		return
+	}
+
+	// Loop through frames
+	cs = make([]float64, 2*i.w)
+	var sum float64
+	for j := range cs {
+		// Get distance between the output sample and the frame, in frames
+		d := float64(i.w-1-j) + float64(phase)/float64(i.l)
+
+		// Sinc
+		c := i.fc
+		if x := math.Pi * i.fc * d; x != 0 {
+			c *= math.Sin(x) / x
+		}
+
+		// Window
+		if x := d / float64(i.w); math.Abs(x) < 1 {
+			c *= 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+		} else {
+			c = 0
+		}
+		cs[j] = c
+		sum += c
+	}
+
+	// Normalize
+	if sum != 0 {
+		for j := range cs {
+			cs[j] /= sum
+		}
+	}
+
+	// Cache
+	if i.l <= sampleRateInterpolatorMaxCachedPhases {
+		i.cs[phase] = cs
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package astipcm
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -77,3 +78,103 @@ func TestSampleRateConverter(t *testing.T) {
 	}
 	assert.Equal(t, []int{1, 2, 1, 2, 3, 4, 3, 4, 5, 6, 7, 8, 7, 8, 9, 10, 9, 10}, o)
 }
+
+func TestSampleRateConverter_Linear(t *testing.T) {
+	// Create sample func
+	var o []int
+	var sampleFunc = func(s int) (err error) {
+		o = append(o, s)
+		return
+	}
+
+	// Invalid options
+	for _, v := range []SampleRateConverterOptions{
+		{DstSampleRate: 2, Mode: "invalid", NumChannels: 1, SrcSampleRate: 1},
+		{DstSampleRate: 2, NumChannels: 1},
+		{NumChannels: 1, SrcSampleRate: 1},
+		{DstSampleRate: 2, SrcSampleRate: 1},
+	} {
+		_, err := NewSampleRateConverterWithOptions(v)
+		assert.Error(t, err)
+	}
+
+	// Src sample rate < dst sample rate
+	c, err := NewSampleRateConverterWithOptions(SampleRateConverterOptions{
+		DstSampleRate: 2,
+		Mode:          ResamplingModeLinear,
+		NumChannels:   1,
+		SampleFunc:    sampleFunc,
+		SrcSampleRate: 1,
+	})
+	assert.NoError(t, err)
+	for _, s := range []int{10, 20, 30, 40} {
+		c.Add(s)
+	}
+	assert.Equal(t, []int{10, 15, 20, 25, 30, 35}, o)
+	assert.NoError(t, c.Flush())
+	assert.Equal(t, []int{10, 15, 20, 25, 30, 35, 40, 40}, o)
+
+	// Src sample rate > dst sample rate with multi channels
+	o = []int{}
+	c, err = NewSampleRateConverterWithOptions(SampleRateConverterOptions{
+		DstSampleRate: 2,
+		Mode:          ResamplingModeLinear,
+		NumChannels:   2,
+		SampleFunc:    sampleFunc,
+		SrcSampleRate: 3,
+	})
+	assert.NoError(t, err)
+	for _, s := range []int{0, 0, 30, -30, 60, -60, 90, -90, 120, -120, 150, -150, 180, -180} {
+		c.Add(s)
+	}
+	assert.Equal(t, []int{0, 0, 45, -45, 90, -90, 135, -135}, o)
+
+	// Reset
+	o = []int{}
+	c.Reset()
+	for _, s := range []int{30, -30, 60, -60} {
+		c.Add(s)
+	}
+	assert.Equal(t, []int{30, -30}, o)
+}
+
+func TestSampleRateConverter_Sinc(t *testing.T) {
+	// Create sample func
+	var o []int
+	var sampleFunc = func(s int) (err error) {
+		o = append(o, s)
+		return
+	}
+
+	// Loop through frequencies
+	for _, v := range []struct {
+		amplitude float64
+		frequency float64
+	}{
+		{amplitude: 10000, frequency: 1000},
+		{amplitude: 0, frequency: 10000},
+	} {
+		// Create converter
+		o = []int{}
+		c, err := NewSampleRateConverterWithOptions(SampleRateConverterOptions{
+			DstSampleRate: 16000,
+			Mode:          ResamplingModeSinc,
+			NumChannels:   1,
+			SampleFunc:    sampleFunc,
+			SrcSampleRate: 44100,
+		})
+		assert.NoError(t, err)
+
+		// Add sine wave
+		for idx := 0; idx < 44100; idx++ {
+			assert.NoError(t, c.Add(int(math.Round(10000*math.Sin(2*math.Pi*v.frequency*float64(idx)/44100)))))
+		}
+		assert.NoError(t, c.Flush())
+		assert.Len(t, o, 16000)
+
+		// Frequencies below the dst Nyquist frequency are kept whereas the others are filtered out
+		for idx := 1000; idx < 15000; idx++ {
+			assert.InDelta(t, v.amplitude*math.Sin(2*math.Pi*v.frequency*float64(idx)/16000), o[idx], 50)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package astipcm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// Wav format codes
+const (
+	wavFormatCodeExtensible = 0xfffe
+	wavFormatCodeFloat      = 0x3
+	wavFormatCodePCM        = 0x1
+)
+
+// WavFormat represents a wav format
+type WavFormat struct {
+	// ChannelMask is only used by WAVE_FORMAT_EXTENSIBLE files
+	ChannelMask  uint32
+	NumChannels  int
+	SampleFormat SampleFormat
+	SampleRate   int
+	// ValidBitDepth is the number of meaningful bits in each sample. Samples are nonetheless decoded and encoded with
+	// SampleFormat.BitDepth. Defaults to SampleFormat.BitDepth.
+	ValidBitDepth int
+}
+
+// Mono and stereo float samples can be written as plain WAVE_FORMAT_IEEE_FLOAT
+func (f WavFormat) isExtensible() bool {
+	return f.ChannelMask > 0 || f.NumChannels > 2 || f.ValidBitDepth != f.SampleFormat.BitDepth ||
+		(f.SampleFormat.Encoding != SampleEncodingFloat && f.SampleFormat.BitDepth > 16)
+}
+
+// WavReader reads samples from a wav stream
+// Samples are interleaved
+type WavReader struct {
+	*Decoder
+	f WavFormat
+}
+
+// NewWavReader parses the wav header and creates a new wav reader positioned at the beginning of the samples
+func NewWavReader(r io.Reader) (wr *WavReader, err error) {
+	// Read RIFF header
+	b := make([]byte, 12)
+	if _, err = io.ReadFull(r, b); err != nil {
+		err = errors.Wrap(err, "astipcm: reading riff header failed")
+		return
+	}
+	if string(b[:4]) != "RIFF" || string(b[8:]) != "WAVE" {
+		err = fmt.Errorf("astipcm: invalid riff header %q", b)
+		return
+	}
+
+	// Loop through chunks
+	wr = &WavReader{}
+	var hasFormat bool
+	for {
+		// Read chunk header
+		if _, err = io.ReadFull(r, b[:8]); err != nil {
+			err = errors.Wrap(err, "astipcm: reading chunk header failed")
+			return
+		}
+		id, size := string(b[:4]), binary.LittleEndian.Uint32(b[4:8])
+
+		// Switch on chunk id
+		switch id {
+		case "fmt ":
+			// Read chunk
+			c := make([]byte, size+size%2)
+			if _, err = io.ReadFull(r, c); err != nil {
+				err = errors.Wrap(err, "astipcm: reading fmt chunk failed")
+				return
+			}
+
+			// Parse chunk
+			if wr.f, err = parseWavFormat(c[:size]); err != nil {
+				err = errors.Wrap(err, "astipcm: parsing fmt chunk failed")
+				return
+			}
+			hasFormat = true
+		case "data":
+			// No format
+			if !hasFormat {
+				err = errors.New("astipcm: data chunk found before fmt chunk")
+				return
+			}
+
+			// Streamed files may not know the data size in advance, in which case it is set to 0xffffffff
+			dr := r
+			if size != 0xffffffff {
+				dr = io.LimitReader(r, int64(size))
+			}
+
+			// Create decoder
+			if wr.Decoder, err = NewDecoder(dr, wr.f.SampleFormat); err != nil {
+				err = errors.Wrap(err, "astipcm: creating decoder failed")
+				return
+			}
+			return
+		default:
+			// Skip chunk
+			if _, err = io.CopyN(ioutil.Discard, r, int64(size+size%2)); err != nil {
+				err = errors.Wrapf(err, "astipcm: skipping %q chunk failed", id)
+				return
+			}
+		}
+	}
+}
+
+func parseWavFormat(b []byte) (f WavFormat, err error) {
+	// Invalid length
+	if len(b) < 16 {
+		err = fmt.Errorf("astipcm: invalid fmt chunk length %d", len(b))
+		return
+	}
+
+	// Parse
+	code := binary.LittleEndian.Uint16(b[0:2])
+	f.NumChannels = int(binary.LittleEndian.Uint16(b[2:4]))
+	f.SampleRate = int(binary.LittleEndian.Uint32(b[4:8]))
+	f.SampleFormat.BitDepth = int(binary.LittleEndian.Uint16(b[14:16]))
+	f.ValidBitDepth = f.SampleFormat.BitDepth
+
+	// Extensible
+	if code == wavFormatCodeExtensible {
+		// Invalid length
+		if len(b) < 40 {
+			err = fmt.Errorf("astipcm: invalid extensible fmt chunk length %d", len(b))
+			return
+		}
+
+		// Parse
+		if v := int(binary.LittleEndian.Uint16(b[18:20])); v > 0 {
+			f.ValidBitDepth = v
+		}
+		f.ChannelMask = binary.LittleEndian.Uint32(b[20:24])
+		code = binary.LittleEndian.Uint16(b[24:26])
+	}
+
+	// Switch on format code
+	switch code {
+	case wavFormatCodeFloat:
+		f.SampleFormat.Encoding = SampleEncodingFloat
+	case wavFormatCodePCM:
+		// 8 bits samples are unsigned
+		f.SampleFormat.Encoding = SampleEncodingSigned
+		if f.SampleFormat.BitDepth == 8 {
+			f.SampleFormat.Encoding = SampleEncodingUnsigned
+		}
+	default:
+		err = fmt.Errorf("astipcm: unsupported format code %#x", code)
+		return
+	}
+	return
+}
+
+// Format returns the wav format
+func (r *WavReader) Format() WavFormat {
+	return r.f
+}
+
+// WavWriter writes samples to a wav stream
+// Samples are interleaved
+type WavWriter struct {
+	*Encoder
+	dataSize       uint32
+	dataSizeOffset int64
+	f              WavFormat
+	w              io.WriteSeeker
+}
+
+// NewWavWriter writes the wav header and creates a new wav writer
+// Only SampleFormat.BitDepth and whether SampleFormat.Encoding is float are taken into account since wav imposes the
+// rest. Chunk sizes are written on Close.
+func NewWavWriter(w io.WriteSeeker, f WavFormat) (ww *WavWriter, err error) {
+	// Update format
+	f.SampleFormat.BigEndian = false
+	if f.SampleFormat.Encoding != SampleEncodingFloat {
+		f.SampleFormat.Encoding = SampleEncodingSigned
+		if f.SampleFormat.BitDepth == 8 {
+			f.SampleFormat.Encoding = SampleEncodingUnsigned
+		}
+	}
+	if f.ValidBitDepth == 0 {
+		f.ValidBitDepth = f.SampleFormat.BitDepth
+	}
+
+	// Validate format
+	if err = f.SampleFormat.Validate(); err != nil {
+		return
+	}
+
+	// Create writer
+	ww = &WavWriter{
+		f: f,
+		w: w,
+	}
+
+	// Create encoder
+	if ww.Encoder, err = NewEncoder(ww, f.SampleFormat); err != nil {
+		err = errors.Wrap(err, "astipcm: creating encoder failed")
+		return
+	}
+
+	// Write header
+	if err = ww.writeHeader(); err != nil {
+		err = errors.Wrap(err, "astipcm: writing header failed")
+		return
+	}
+	return
+}
+
+func (w *WavWriter) writeHeader() (err error) {
+	// Get format code
+	code := uint16(wavFormatCodePCM)
+	if w.f.SampleFormat.Encoding == SampleEncodingFloat {
+		code = wavFormatCodeFloat
+	}
+
+	// Create fmt chunk
+	blockAlign := w.f.NumChannels * w.f.SampleFormat.BytesPerSample()
+	f := make([]byte, 16)
+	binary.LittleEndian.PutUint16(f[0:2], code)
+	binary.LittleEndian.PutUint16(f[2:4], uint16(w.f.NumChannels))
+	binary.LittleEndian.PutUint32(f[4:8], uint32(w.f.SampleRate))
+	binary.LittleEndian.PutUint32(f[8:12], uint32(w.f.SampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(f[12:14], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(f[14:16], uint16(w.f.SampleFormat.BitDepth))
+
+	// Extensible
+	if w.f.isExtensible() {
+		binary.LittleEndian.PutUint16(f[0:2], wavFormatCodeExtensible)
+		e := make([]byte, 24)
+		binary.LittleEndian.PutUint16(e[0:2], 22)
+		binary.LittleEndian.PutUint16(e[2:4], uint16(w.f.ValidBitDepth))
+		binary.LittleEndian.PutUint32(e[4:8], w.f.ChannelMask)
+
+		// Sub format GUID is xxxxxxxx-0000-0010-8000-00aa00389b71 where the first bytes are the format code
+		binary.LittleEndian.PutUint16(e[8:10], code)
+		copy(e[12:], []byte{0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71})
+		f = append(f, e...)
+	} else if code == wavFormatCodeFloat {
+		// Non PCM formats must have an extension size
+		f = append(f, 0, 0)
+	}
+
+	// Create header
+	h := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	h = append(h, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(h[16:20], uint32(len(f)))
+	h = append(h, f...)
+	h = append(h, []byte("data\x00\x00\x00\x00")...)
+	w.dataSizeOffset = int64(len(h) - 4)
+
+	// Write
+	if _, err = w.w.Write(h); err != nil {
+		err = errors.Wrap(err, "astipcm: writing failed")
+		return
+	}
+	return
+}
+
+// Format returns the wav format
+func (w *WavWriter) Format() WavFormat {
+	return w.f
+}
+
+// Write implements the io.Writer interface so that raw interleaved samples can be written as well
+// Samples must be encoded with the writer format
+func (w *WavWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.dataSize += uint32(n)
+	return
+}
+
+// Close pads the data chunk and writes chunk sizes
+// It doesn't close the underlying writer
+func (w *WavWriter) Close() (err error) {
+	// Pad data chunk
+	var pad uint32
+	if w.dataSize%2 > 0 {
+		if _, err = w.w.Write([]byte{0}); err != nil {
+			err = errors.Wrap(err, "astipcm: writing pad byte failed")
+			return
+		}
+		pad = 1
+	}
+
+	// Write sizes
+	b := make([]byte, 4)
+	for _, v := range []struct {
+		offset int64
+		size   uint32
+	}{
+		{offset: 4, size: uint32(w.dataSizeOffset) - 4 + w.dataSize + pad},
+		{offset: w.dataSizeOffset, size: w.dataSize},
+	} {
+		// Seek
+		if _, err = w.w.Seek(v.offset, io.SeekStart); err != nil {
+			err = errors.Wrapf(err, "astipcm: seeking to %d failed", v.offset)
+			return
+		}
+
+		// Write
+		binary.LittleEndian.PutUint32(b, v.size)
+		if _, err = w.w.Write(b); err != nil {
+			err = errors.Wrapf(err, "astipcm: writing size at %d failed", v.offset)
+			return
+		}
+	}
+
+	// Seek back to the end
+	if _, err = w.w.Seek(0, io.SeekEnd); err != nil {
+		err = errors.Wrap(err, "astipcm: seeking to end failed")
+		return
+	}
+	return
+}
@@ -0,0 +1,105 @@
+package astipcm
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWav(t *testing.T) {
+	// Loop through formats
+	for _, v := range []struct {
+		code       uint16
+		f          WavFormat
+		headerSize int
+		ss         []int
+	}{
+		{code: wavFormatCodePCM, f: WavFormat{NumChannels: 2, SampleFormat: SampleFormat{BitDepth: 16, Encoding: SampleEncodingSigned}, SampleRate: 44100, ValidBitDepth: 16}, headerSize: 44, ss: []int{-32768, 32767, 0, 1}},
+		{code: wavFormatCodePCM, f: WavFormat{NumChannels: 1, SampleFormat: SampleFormat{BitDepth: 8, Encoding: SampleEncodingUnsigned}, SampleRate: 8000, ValidBitDepth: 8}, headerSize: 44, ss: []int{-128, 127, 3}},
+		{f: WavFormat{ChannelMask: 0x3f, NumChannels: 6, SampleFormat: SampleFormat{BitDepth: 24, Encoding: SampleEncodingSigned}, SampleRate: 48000, ValidBitDepth: 20}, headerSize: 68, ss: []int{-8388608, 8388607, 1, 2, 3, 4}},
+		{code: wavFormatCodeFloat, f: WavFormat{NumChannels: 2, SampleFormat: SampleFormat{BitDepth: 32, Encoding: SampleEncodingFloat}, SampleRate: 16000, ValidBitDepth: 32}, headerSize: 46, ss: []int{2147483647, -2147483647, 0, 1073741824}},
+		{f: WavFormat{ChannelMask: 0x7, NumChannels: 3, SampleFormat: SampleFormat{BitDepth: 32, Encoding: SampleEncodingFloat}, SampleRate: 16000, ValidBitDepth: 32}, headerSize: 68, ss: []int{2147483647, -2147483647, 0}},
+	} {
+		// Write
+		f, err := ioutil.TempFile("", "astipcm")
+		assert.NoError(t, err)
+		defer os.Remove(f.Name())
+		w, err := NewWavWriter(f, v.f)
+		assert.NoError(t, err)
+		assert.NoError(t, w.WriteSamples(v.ss))
+		assert.NoError(t, w.Close())
+		assert.NoError(t, f.Close())
+
+		// Check sizes
+		b, err := ioutil.ReadFile(f.Name())
+		assert.NoError(t, err)
+		dataSize := len(v.ss) * v.f.SampleFormat.BytesPerSample()
+		assert.Equal(t, v.headerSize+dataSize+dataSize%2, len(b))
+		assert.Equal(t, uint32(len(b)-8), uint32(b[4])|uint32(b[5])<<8|uint32(b[6])<<16|uint32(b[7])<<24)
+		code := v.code
+		if code == 0 {
+			code = wavFormatCodeExtensible
+		}
+		assert.Equal(t, code, uint16(b[20])|uint16(b[21])<<8)
+
+		// Read
+		r, err := NewWavReader(bytes.NewReader(b))
+		assert.NoError(t, err)
+		assert.Equal(t, v.f, w.Format())
+		assert.Equal(t, v.f, r.Format())
+		ss := make([]int, len(v.ss)+1)
+		n, err := r.ReadSamples(ss)
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, v.ss, ss[:n])
+	}
+}
+
+func TestNewWavReader(t *testing.T) {
+	// Invalid header
+	_, err := NewWavReader(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI ")))
+	assert.Error(t, err)
+
+	// Unknown chunks are skipped
+	r, err := NewWavReader(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVE" +
+		"LIST\x03\x00\x00\x00abc\x00" +
+		"fmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\x80\x3e\x00\x00\x02\x00\x10\x00" +
+		"data\x04\x00\x00\x00\x01\x00\xff\xff" +
+		"junk")))
+	assert.NoError(t, err)
+	assert.Equal(t, WavFormat{NumChannels: 1, SampleFormat: SampleFormat{BitDepth: 16, Encoding: SampleEncodingSigned}, SampleRate: 8000, ValidBitDepth: 16}, r.Format())
+	var ss []int
+	assert.NoError(t, r.Forward(func(s int) error {
+		ss = append(ss, s)
+		return nil
+	}))
+	assert.Equal(t, []int{1, -1}, ss)
+
+	// Empty data chunk followed by another chunk
+	r, err = NewWavReader(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVE" +
+		"fmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\x80\x3e\x00\x00\x02\x00\x10\x00" +
+		"data\x00\x00\x00\x00" +
+		"LIST\x04\x00\x00\x00abcd")))
+	assert.NoError(t, err)
+	ss = []int{}
+	assert.NoError(t, r.Forward(func(s int) error {
+		ss = append(ss, s)
+		return nil
+	}))
+	assert.Empty(t, ss)
+
+	// Unbounded data chunk
+	r, err = NewWavReader(bytes.NewReader([]byte("RIFF\xff\xff\xff\xffWAVE" +
+		"fmt \x10\x00\x00\x00\x01\x00\x01\x00\x40\x1f\x00\x00\x80\x3e\x00\x00\x02\x00\x10\x00" +
+		"data\xff\xff\xff\xff\x01\x00\xff\xff\x02\x00")))
+	assert.NoError(t, err)
+	ss = []int{}
+	assert.NoError(t, r.Forward(func(s int) error {
+		ss = append(ss, s)
+		return nil
+	}))
+	assert.Equal(t, []int{1, -1, 2}, ss)
+}
@@ -32,13 +32,18 @@ func (s *DurationRatioStat) Add(k interface{}) {
 }
 
 // Done indicates the duration is now done
+// Keys that were not added, for instance because the stat was not started yet, are ignored
 func (s *DurationRatioStat) Done(k interface{}) {
 	s.m.Lock()
 	defer s.m.Unlock()
 	if !s.isStarted {
 		return
 	}
-	s.d += time.Now().Sub(s.startedAt[k])
+	t, ok := s.startedAt[k]
+	if !ok {
+		return
+	}
+	s.d += time.Now().Sub(t)
 	delete(s.startedAt, k)
 }
 
@@ -0,0 +1,28 @@
+package astistat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationRatioStat(t *testing.T) {
+	// Init
+	s := NewDurationRatioStat()
+	s.Start()
+	defer s.Stop()
+
+	// Done without Add is ignored
+	s.Done("unknown")
+	assert.Equal(t, float64(0), s.Value(time.Second))
+
+	// Done after Add
+	s.Add("k")
+	time.Sleep(10 * time.Millisecond)
+	s.Done("k")
+	v := s.Value(100 * time.Millisecond).(float64)
+	assert.True(t, v >= 10)
+	s.Done("k")
+	assert.Equal(t, float64(0), s.Value(time.Second))
+}
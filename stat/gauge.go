@@ -0,0 +1,80 @@
+package astistat
+
+import (
+	"sync"
+	"time"
+)
+
+// GaugeStat is an object capable of reporting a value that can go up and down
+// Unlike other stats, its value is kept between periods and can be set before the stater is started.
+type GaugeStat struct {
+	m *sync.Mutex
+	v float64
+}
+
+// NewGaugeStat creates a new gauge stat
+func NewGaugeStat() *GaugeStat {
+	return &GaugeStat{m: &sync.Mutex{}}
+}
+
+// Add adds delta to the gauge
+func (s *GaugeStat) Add(delta float64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.v += delta
+}
+
+// Set sets the gauge
+func (s *GaugeStat) Set(v float64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.v = v
+}
+
+// Start implements the StatHandler interface
+func (s *GaugeStat) Start() {}
+
+// Stop implements the StatHandler interface
+func (s *GaugeStat) Stop() {}
+
+// Value implements the StatHandler interface
+func (s *GaugeStat) Value(delta time.Duration) interface{} {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.v
+}
+
+// CounterStat is an object capable of reporting a total that only goes up
+// Unlike IncrementStat which reports a rate, it reports the total since it has been created.
+type CounterStat struct {
+	m *sync.Mutex
+	v int64
+}
+
+// NewCounterStat creates a new counter stat
+func NewCounterStat() *CounterStat {
+	return &CounterStat{m: &sync.Mutex{}}
+}
+
+// Add increments the counter. Negative deltas are ignored.
+func (s *CounterStat) Add(delta int64) {
+	if delta < 0 {
+		return
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.v += delta
+}
+
+// Start implements the StatHandler interface
+func (s *CounterStat) Start() {}
+
+// Stop implements the StatHandler interface
+func (s *CounterStat) Stop() {}
+
+// Value implements the StatHandler interface
+func (s *CounterStat) Value(delta time.Duration) interface{} {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.v
+}
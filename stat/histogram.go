@@ -0,0 +1,159 @@
+package astistat
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HistogramBucket represents a histogram bucket
+// The last bucket counts values above the highest bound and its UpperBound is +Inf
+type HistogramBucket struct {
+	Count      int64
+	UpperBound float64
+}
+
+// HistogramValue represents the value of a histogram stat over a period
+type HistogramValue struct {
+	SummaryValue
+	Buckets []HistogramBucket
+}
+
+// HistogramStat is an object capable of counting values added over each period in fixed buckets
+// Unlike SummaryStat, its memory usage doesn't depend on the number of values. Percentiles are estimated by linear
+// interpolation within buckets and are therefore as precise as the buckets are narrow.
+type HistogramStat struct {
+	bounds    []float64
+	count     int64
+	counts    []int64
+	isStarted bool
+	m         *sync.Mutex
+	max       float64
+	min       float64
+	sum       float64
+}
+
+// NewHistogramStat creates a new histogram stat with buckets upper bounds
+// A last bucket containing values greater than the highest bound is added automatically.
+func NewHistogramStat(bounds []float64) *HistogramStat {
+	bs := append([]float64(nil), bounds...)
+	sort.Float64s(bs)
+	return &HistogramStat{
+		bounds: bs,
+		counts: make([]int64, len(bs)+1),
+		m:      &sync.Mutex{},
+	}
+}
+
+// Add adds a value
+func (s *HistogramStat) Add(v float64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.isStarted {
+		return
+	}
+
+	// Update min, max and mean
+	s.count++
+	s.sum += v
+	if s.count == 1 || v < s.min {
+		s.min = v
+	}
+	if s.count == 1 || v > s.max {
+		s.max = v
+	}
+
+	// Update bucket
+	s.counts[sort.SearchFloat64s(s.bounds, v)]++
+}
+
+// AddDuration adds a duration expressed in milliseconds
+func (s *HistogramStat) AddDuration(d time.Duration) {
+	s.Add(float64(d) / float64(time.Millisecond))
+}
+
+// Start implements the StatHandler interface
+func (s *HistogramStat) Start() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.reset()
+	s.isStarted = true
+}
+
+// Stop implements the StatHandler interface
+func (s *HistogramStat) Stop() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.isStarted = false
+}
+
+// Assumes the lock is held
+func (s *HistogramStat) reset() {
+	s.count = 0
+	for idx := range s.counts {
+		s.counts[idx] = 0
+	}
+	s.max = 0
+	s.min = 0
+	s.sum = 0
+}
+
+// Value implements the StatHandler interface
+func (s *HistogramStat) Value(delta time.Duration) interface{} {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Compute value
+	v := HistogramValue{SummaryValue: SummaryValue{
+		Count: s.count,
+		Max:   s.max,
+		Min:   s.min,
+	}}
+	for idx, c := range s.counts {
+		b := HistogramBucket{Count: c, UpperBound: math.Inf(1)}
+		if idx < len(s.bounds) {
+			b.UpperBound = s.bounds[idx]
+		}
+		v.Buckets = append(v.Buckets, b)
+	}
+	if s.count > 0 {
+		v.Mean = s.sum / float64(s.count)
+		v.P50 = s.percentile(50)
+		v.P95 = s.percentile(95)
+		v.P99 = s.percentile(99)
+	}
+
+	// Reset
+	s.reset()
+	return v
+}
+
+// Assumes the lock is held
+func (s *HistogramStat) percentile(p float64) float64 {
+	// Get rank
+	r := p / 100 * float64(s.count)
+
+	// Loop through buckets
+	var c int64
+	for idx, n := range s.counts {
+		if n == 0 || float64(c+n) < r {
+			c += n
+			continue
+		}
+
+		// Get bucket bounds, making sure they don't exceed min and max
+		lower, upper := s.min, s.max
+		if idx > 0 && s.bounds[idx-1] > lower {
+			lower = s.bounds[idx-1]
+		}
+		if idx < len(s.bounds) && s.bounds[idx] < upper {
+			upper = s.bounds[idx]
+		}
+
+		// Interpolate
+		return lower + (upper-lower)*(r-float64(c))/float64(n)
+	}
+	return s.max
+}
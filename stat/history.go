@@ -0,0 +1,245 @@
+package astistat
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatPoint represents the value of a stat at a given time
+type StatPoint struct {
+	At    time.Time
+	Value interface{}
+}
+
+// Records stat values in history and drops values older than the retention
+// Stats are expected in the same order as s.ss
+// Assumes the lock is held
+func (s *Stater) record(now time.Time, stats []Stat) {
+	// History is disabled
+	if s.o.HistoryRetention <= 0 {
+		return
+	}
+
+	// Loop through stats
+	for idx, st := range stats {
+		// Add point
+		id := s.ss[idx].id
+		ps := append(s.h[id], StatPoint{
+			At:    now,
+			Value: st.Value,
+		})
+
+		// Drop old points
+		var i int
+		for i < len(ps) && now.Sub(ps[i].At) > s.o.HistoryRetention {
+			i++
+		}
+		s.h[id] = ps[i:]
+	}
+}
+
+// History returns the values of a stat recorded between from and to, oldest first
+// A zero from or to means no bound
+func (s *Stater) History(id StatID, from, to time.Time) (ps []StatPoint) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for _, p := range s.h[id] {
+		if (!from.IsZero() && p.At.Before(from)) || (!to.IsZero() && p.At.After(to)) {
+			continue
+		}
+		ps = append(ps, p)
+	}
+	return
+}
+
+// Alert conditions
+const (
+	AlertConditionAbove = "above"
+	AlertConditionBelow = "below"
+)
+
+// AlertRuleID represents the id of an alert rule
+type AlertRuleID uint64
+
+// AlertRule represents a threshold alert rule
+type AlertRule struct {
+	// Condition is either AlertConditionAbove or AlertConditionBelow
+	Condition string
+	// Field is the field checked for summary and histogram stats, e.g. "p99". Defaults to "value" which is only valid
+	// for stats with a single numeric value.
+	Field string
+	// For is the duration during which the condition must be met before the alert fires
+	For  time.Duration
+	Name string
+	// OnFire and OnResolve are called in the stater goroutine and therefore must not block. OnResolve is also called
+	// when the stat of a firing rule is removed.
+	OnFire    func(e AlertEvent)
+	OnResolve func(e AlertEvent)
+	StatID    StatID
+	Threshold float64
+}
+
+// AlertEvent represents an alert firing or resolving
+type AlertEvent struct {
+	At   time.Time
+	Rule AlertRule
+	// Since is when the condition started to be met
+	Since time.Time
+	Stat  StatMetadata
+	Value float64
+}
+
+type alertRule struct {
+	firing bool
+	r      AlertRule
+	since  time.Time
+	value  float64
+}
+
+type alertEventHandler struct {
+	e  AlertEvent
+	fn func(e AlertEvent)
+}
+
+// AddAlertRule adds an alert rule evaluated each time stats are computed
+// The rule is removed when its stat is removed
+func (s *Stater) AddAlertRule(r AlertRule) (id AlertRuleID, err error) {
+	// Validate condition
+	if r.Condition != AlertConditionAbove && r.Condition != AlertConditionBelow {
+		err = fmt.Errorf("astistat: invalid alert condition %s", r.Condition)
+		return
+	}
+
+	// Default field
+	if r.Field == "" {
+		r.Field = sinkFieldValue
+	}
+
+	// Add rule
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.ruleID++
+	s.rs[s.ruleID] = &alertRule{r: r}
+	id = s.ruleID
+	return
+}
+
+// RemoveAlertRule removes an alert rule
+func (s *Stater) RemoveAlertRule(id AlertRuleID) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	delete(s.rs, id)
+}
+
+// Evaluates alert rules and returns the events that need to be handled
+// Stats are expected in the same order as s.ss
+// Assumes the lock is held
+func (s *Stater) evaluate(now time.Time, stats []Stat) (es []alertEventHandler) {
+	// No rules
+	if len(s.rs) == 0 {
+		return
+	}
+
+	// Index stats
+	idxs := make(map[StatID]int)
+	for idx, v := range s.ss {
+		idxs[v.id] = idx
+	}
+
+	// Loop through rules
+	for _, r := range s.rs {
+		// Get value
+		idx, ok := idxs[r.r.StatID]
+		if !ok {
+			continue
+		}
+		v, ok := statField(stats[idx], r.r.Field)
+		if !ok {
+			continue
+		}
+
+		// Check condition
+		r.value = v
+		met := v > r.r.Threshold
+		if r.r.Condition == AlertConditionBelow {
+			met = v < r.r.Threshold
+		}
+
+		// Create event
+		e := AlertEvent{
+			At:    now,
+			Rule:  r.r,
+			Since: r.since,
+			Stat:  stats[idx].StatMetadata,
+			Value: v,
+		}
+
+		// Update state
+		if met {
+			if r.since.IsZero() {
+				r.since = now
+				e.Since = now
+			}
+			if !r.firing && now.Sub(r.since) >= r.r.For {
+				r.firing = true
+				if r.r.OnFire != nil {
+					es = append(es, alertEventHandler{e: e, fn: r.r.OnFire})
+				}
+			}
+		} else {
+			if r.firing {
+				r.firing = false
+				if r.r.OnResolve != nil {
+					es = append(es, alertEventHandler{e: e, fn: r.r.OnResolve})
+				}
+			}
+			r.since = time.Time{}
+		}
+	}
+	return
+}
+
+// removeAlertRules removes the alert rules of a stat and returns the events that need to be handled for the rules that
+// were firing
+// Assumes the lock is held
+func (s *Stater) removeAlertRules(st stat) (es []alertEventHandler) {
+	now := time.Now()
+	for id, r := range s.rs {
+		// Invalid stat
+		if r.r.StatID != st.id {
+			continue
+		}
+
+		// Resolve
+		if r.firing && r.r.OnResolve != nil {
+			es = append(es, alertEventHandler{
+				e: AlertEvent{
+					At:    now,
+					Rule:  r.r,
+					Since: r.since,
+					Stat:  st.m,
+					Value: r.value,
+				},
+				fn: r.r.OnResolve,
+			})
+		}
+
+		// Remove
+		delete(s.rs, id)
+	}
+	return
+}
+
+// statField returns the numeric value of a stat field
+func statField(s Stat, key string) (v float64, ok bool) {
+	ms := flattenStats("", []Stat{s})
+	if len(ms) == 0 {
+		return
+	}
+	for _, f := range ms[0].fields {
+		if f.key == key {
+			return f.value, true
+		}
+	}
+	return
+}
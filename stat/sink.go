@@ -0,0 +1,174 @@
+package astistat
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// StatsHandleFuncs returns a StatsHandleFunc forwarding stats to several funcs, for instance several sinks
+func StatsHandleFuncs(fns ...StatsHandleFunc) StatsHandleFunc {
+	return func(stats []Stat) {
+		for _, fn := range fns {
+			fn(stats)
+		}
+	}
+}
+
+// sinkMetric represents a stat flattened into numeric fields
+type sinkMetric struct {
+	fields []sinkField
+	labels map[string]string
+	name   string
+	unit   string
+}
+
+type sinkField struct {
+	key   string
+	value float64
+}
+
+// Key of the field of stats with a single value
+const sinkFieldValue = "value"
+
+// metricName converts a stat label into a metric name: "Listen ratio" with prefix "app" becomes "app.listen_ratio"
+func metricName(prefix, label string) string {
+	n := metricNamePart(label)
+	if prefix != "" {
+		n = prefix + "." + n
+	}
+	return n
+}
+
+// metricNamePart lowercases s and replaces characters that are neither letters nor digits with "_"
+func metricNamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, strings.TrimSpace(s))
+}
+
+// flattenStats converts stats into metrics, skipping values that are not numeric
+// Non finite fields (NaN, +Inf and -Inf) are skipped as well since most backends reject them
+func flattenStats(prefix string, stats []Stat) (ms []sinkMetric) {
+	for _, s := range stats {
+		// Get fields
+		var fs []sinkField
+		switch v := s.Value.(type) {
+		case HistogramValue:
+			fs = summaryFields(v.SummaryValue)
+		case SummaryValue:
+			fs = summaryFields(v)
+		default:
+			f, ok := toFloat64(v)
+			if !ok {
+				continue
+			}
+			fs = []sinkField{{key: sinkFieldValue, value: f}}
+		}
+
+		// Create metric
+		m := sinkMetric{
+			labels: s.Labels,
+			name:   metricName(prefix, s.Label),
+			unit:   s.Unit,
+		}
+		for _, f := range fs {
+			if !math.IsNaN(f.value) && !math.IsInf(f.value, 0) {
+				m.fields = append(m.fields, f)
+			}
+		}
+		if len(m.fields) == 0 {
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return
+}
+
+func summaryFields(v SummaryValue) []sinkField {
+	return []sinkField{
+		{key: "count", value: float64(v.Count)},
+		{key: "max", value: v.Max},
+		{key: "mean", value: v.Mean},
+		{key: "min", value: v.Min},
+		{key: "p50", value: v.P50},
+		{key: "p95", value: v.P95},
+		{key: "p99", value: v.P99},
+	}
+}
+
+func toFloat64(i interface{}) (f float64, ok bool) {
+	ok = true
+	switch v := i.(type) {
+	case bool:
+		if v {
+			f = 1
+		}
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case int:
+		f = float64(v)
+	case int32:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case time.Duration:
+		f = float64(v) / float64(time.Millisecond)
+	case uint:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	default:
+		ok = false
+	}
+	return
+}
+
+// sortedLabels returns the metric labels as key/value pairs sorted by key, joined by sep
+func (m sinkMetric) sortedLabels(sep string, escape func(string) string) (ls []string) {
+	for k, v := range m.labels {
+		ls = append(ls, escape(k)+sep+escape(v))
+	}
+	sort.Strings(ls)
+	return
+}
+
+// fieldName returns the name of a metric's field when fields are exported as separate metrics
+func (m sinkMetric) fieldName(f sinkField) string {
+	if f.key == sinkFieldValue {
+		return m.name
+	}
+	return m.name + "." + f.key
+}
+
+// Max size of a UDP packet that fits in most networks' MTU
+const udpMaxPacketSize = 1432
+
+// udpPackets splits newline-delimited lines into packets that fit in udpMaxPacketSize, unless a single line doesn't
+func udpPackets(b []byte) (ps [][]byte) {
+	var p []byte
+	for _, l := range bytes.Split(bytes.TrimSuffix(b, []byte("\n")), []byte("\n")) {
+		if len(p) > 0 && len(p)+1+len(l) > udpMaxPacketSize {
+			ps = append(ps, p)
+			p = nil
+		}
+		if len(p) > 0 {
+			p = append(p, '\n')
+		}
+		p = append(p, l...)
+	}
+	if len(p) > 0 {
+		ps = append(ps, p)
+	}
+	return
+}
@@ -0,0 +1,219 @@
+package astistat
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// File formats
+const (
+	FileFormatCSV       = "csv"
+	FileFormatJSONLines = "jsonl"
+)
+
+// FileSinkOptions represents file sink options
+type FileSinkOptions struct {
+	// Format defaults to FileFormatCSV
+	Format string
+	// MaxBackups is the number of rotated files kept. Rotated files are named <path>.1, <path>.2, etc. Defaults to 5.
+	MaxBackups int
+	// MaxSize is the size in bytes above which the file is rotated. 0 means no rotation.
+	MaxSize int64
+	Path    string
+	Prefix  string
+}
+
+// FileSink represents a sink writing stats to a CSV or JSON lines file, one row per metric
+// CSV columns are time, name, labels (formatted as k1=v1;k2=v2), unit and value
+type FileSink struct {
+	f    *os.File
+	m    *sync.Mutex // Locks f and size
+	o    FileSinkOptions
+	size int64
+}
+
+type fileSinkRow struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Name   string            `json:"name"`
+	Time   time.Time         `json:"time"`
+	Unit   string            `json:"unit,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// NewFileSink creates a new file sink
+func NewFileSink(o FileSinkOptions) (s *FileSink, err error) {
+	// Default options
+	if o.Format == "" {
+		o.Format = FileFormatCSV
+	}
+	if o.MaxBackups <= 0 {
+		o.MaxBackups = 5
+	}
+
+	// Create sink
+	s = &FileSink{
+		m: &sync.Mutex{},
+		o: o,
+	}
+
+	// Open
+	if err = s.open(); err != nil {
+		err = errors.Wrap(err, "astistat: opening failed")
+		return
+	}
+	return
+}
+
+// Assumes the lock is held
+func (s *FileSink) open() (err error) {
+	// Open file
+	if s.f, err = os.OpenFile(s.o.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		err = errors.Wrapf(err, "astistat: opening %s failed", s.o.Path)
+		return
+	}
+
+	// Get size
+	var fi os.FileInfo
+	if fi, err = s.f.Stat(); err != nil {
+		s.f.Close()
+		s.f = nil
+		err = errors.Wrapf(err, "astistat: stating %s failed", s.o.Path)
+		return
+	}
+	s.size = fi.Size()
+
+	// Write CSV header
+	if s.size == 0 && s.o.Format == FileFormatCSV {
+		if err = s.write([][]string{{"time", "name", "labels", "unit", "value"}}, nil); err != nil {
+			err = errors.Wrap(err, "astistat: writing csv header failed")
+			return
+		}
+	}
+	return
+}
+
+// If anything fails once the file has been closed, it is reopened on the next write
+// Assumes the lock is held
+func (s *FileSink) rotate() (err error) {
+	// Close file
+	f := s.f
+	s.f = nil
+	if err = f.Close(); err != nil {
+		err = errors.Wrapf(err, "astistat: closing %s failed", s.o.Path)
+		return
+	}
+
+	// Shift backups
+	os.Remove(fmt.Sprintf("%s.%d", s.o.Path, s.o.MaxBackups))
+	for idx := s.o.MaxBackups - 1; idx >= 1; idx-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.o.Path, idx), fmt.Sprintf("%s.%d", s.o.Path, idx+1))
+	}
+	if err = os.Rename(s.o.Path, s.o.Path+".1"); err != nil {
+		err = errors.Wrapf(err, "astistat: renaming %s failed", s.o.Path)
+		return
+	}
+
+	// Open
+	return s.open()
+}
+
+// Assumes the lock is held
+func (s *FileSink) write(csvRows [][]string, jsonRows []fileSinkRow) (err error) {
+	// Write
+	w := &countWriter{w: s.f}
+	if s.o.Format == FileFormatJSONLines {
+		e := json.NewEncoder(w)
+		for _, r := range jsonRows {
+			if err = e.Encode(r); err != nil {
+				err = errors.Wrap(err, "astistat: encoding json failed")
+				break
+			}
+		}
+	} else {
+		c := csv.NewWriter(w)
+		if err = c.WriteAll(csvRows); err != nil {
+			err = errors.Wrap(err, "astistat: writing csv failed")
+		}
+	}
+	s.size += w.n
+	return
+}
+
+// Close implements the io.Closer interface
+func (s *FileSink) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
+
+// HandleStats implements the StatsHandleFunc signature
+func (s *FileSink) HandleStats(stats []Stat) {
+	// Get rows
+	n := time.Now()
+	var csvRows [][]string
+	var jsonRows []fileSinkRow
+	for _, m := range flattenStats(s.o.Prefix, stats) {
+		for _, f := range m.fields {
+			if s.o.Format == FileFormatJSONLines {
+				jsonRows = append(jsonRows, fileSinkRow{
+					Labels: m.labels,
+					Name:   m.fieldName(f),
+					Time:   n,
+					Unit:   m.unit,
+					Value:  f.value,
+				})
+			} else {
+				ls := strings.Join(m.sortedLabels("=", func(s string) string { return s }), ";")
+				csvRows = append(csvRows, []string{n.Format(time.RFC3339Nano), m.fieldName(f), ls, m.unit, strconv.FormatFloat(f.value, 'f', -1, 64)})
+			}
+		}
+	}
+
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Reopen file closed by a failed rotation
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			astilog.Error(errors.Wrapf(err, "astistat: reopening %s failed", s.o.Path))
+			return
+		}
+	}
+
+	// Write
+	if err := s.write(csvRows, jsonRows); err != nil {
+		astilog.Error(errors.Wrapf(err, "astistat: writing to %s failed", s.o.Path))
+		return
+	}
+
+	// Rotate
+	if s.o.MaxSize > 0 && s.size >= s.o.MaxSize {
+		if err := s.rotate(); err != nil {
+			astilog.Error(errors.Wrapf(err, "astistat: rotating %s failed", s.o.Path))
+		}
+	}
+}
+
+type countWriter struct {
+	n int64
+	w *os.File
+}
+
+func (w *countWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.n += int64(n)
+	return
+}
@@ -0,0 +1,142 @@
+package astistat
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// InfluxSinkOptions represents InfluxDB sink options
+// Either Addr or URL must be set
+type InfluxSinkOptions struct {
+	// Addr is the address of the UDP listener
+	Addr string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+	Prefix string
+	// Tags are added to each point alongside the stat labels and unit
+	Tags map[string]string
+	// URL is the HTTP write endpoint, e.g. http://localhost:8086/write?db=stats
+	URL string
+}
+
+// InfluxSink represents a sink sending stats using the InfluxDB line protocol
+// Each stat is a measurement whose name is derived from its label. Stats with a single value have a "value" field.
+type InfluxSink struct {
+	c net.Conn
+	o InfluxSinkOptions
+}
+
+// NewInfluxSink creates a new InfluxDB sink
+func NewInfluxSink(o InfluxSinkOptions) (s *InfluxSink, err error) {
+	// Create sink
+	s = &InfluxSink{o: o}
+	if s.o.Client == nil {
+		s.o.Client = http.DefaultClient
+	}
+
+	// Dial
+	if o.URL == "" {
+		if o.Addr == "" {
+			err = errors.New("astistat: either addr or url must be set")
+			return
+		}
+		if s.c, err = net.Dial("udp", o.Addr); err != nil {
+			err = errors.Wrapf(err, "astistat: dialing %s failed", o.Addr)
+			return
+		}
+	}
+	return
+}
+
+// Close implements the io.Closer interface
+func (s *InfluxSink) Close() error {
+	if s.c != nil {
+		return s.c.Close()
+	}
+	return nil
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", "\\,", " ", "\\ ")
+	influxTagEscaper         = strings.NewReplacer(",", "\\,", " ", "\\ ", "=", "\\=")
+)
+
+// lines returns the line protocol representation of stats
+func (s *InfluxSink) lines(stats []Stat, t time.Time) []byte {
+	// Get tags
+	var ts []string
+	for k, v := range s.o.Tags {
+		ts = append(ts, influxTagEscaper.Replace(k)+"="+influxTagEscaper.Replace(v))
+	}
+
+	// Loop through metrics
+	buf := &bytes.Buffer{}
+	for _, m := range flattenStats(s.o.Prefix, stats) {
+		// Add measurement and tags
+		buf.WriteString(influxMeasurementEscaper.Replace(m.name))
+		mts := append(append([]string{}, ts...), m.sortedLabels("=", influxTagEscaper.Replace)...)
+		if m.unit != "" {
+			mts = append(mts, "unit="+influxTagEscaper.Replace(m.unit))
+		}
+		sort.Strings(mts)
+		for _, t := range mts {
+			buf.WriteString("," + t)
+		}
+
+		// Add fields
+		for idx, f := range m.fields {
+			if idx == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(f.key + "=" + strconv.FormatFloat(f.value, 'f', -1, 64))
+		}
+
+		// Add timestamp
+		buf.WriteString(" " + strconv.FormatInt(t.UnixNano(), 10) + "\n")
+	}
+	return buf.Bytes()
+}
+
+// HandleStats implements the StatsHandleFunc signature
+func (s *InfluxSink) HandleStats(stats []Stat) {
+	// Get lines
+	b := s.lines(stats, time.Now())
+	if len(b) == 0 {
+		return
+	}
+
+	// UDP
+	if s.c != nil {
+		for _, p := range udpPackets(b) {
+			if _, err := s.c.Write(p); err != nil {
+				astilog.Error(errors.Wrapf(err, "astistat: writing to %s failed", s.o.Addr))
+			}
+		}
+		return
+	}
+
+	// HTTP
+	resp, err := s.o.Client.Post(s.o.URL, "text/plain; charset=utf-8", bytes.NewReader(b))
+	if err != nil {
+		astilog.Error(errors.Wrapf(err, "astistat: posting to %s failed", s.o.URL))
+		return
+	}
+	defer resp.Body.Close()
+
+	// Check status code
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := ioutil.ReadAll(resp.Body)
+		astilog.Errorf("astistat: posting to %s returned status code %d and body %s", s.o.URL, resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,89 @@
+package astistat
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// StatsDSinkOptions represents StatsD sink options
+type StatsDSinkOptions struct {
+	Addr string
+	// DogStatsD enables DogStatsD tags. Stat labels are sent as tags and the stat unit is sent in a "unit" tag.
+	// Otherwise stat labels sorted by key are folded into the metric name: label "Listen ratio" with labels
+	// {"stream": "1"} becomes "listen_ratio.stream.1".
+	DogStatsD bool
+	Prefix    string
+	// Tags are only sent if DogStatsD is enabled
+	Tags map[string]string
+}
+
+// StatsDSink represents a sink sending stats as StatsD gauges over UDP
+type StatsDSink struct {
+	c net.Conn
+	o StatsDSinkOptions
+}
+
+// NewStatsDSink creates a new StatsD sink
+func NewStatsDSink(o StatsDSinkOptions) (s *StatsDSink, err error) {
+	s = &StatsDSink{o: o}
+	if s.c, err = net.Dial("udp", o.Addr); err != nil {
+		err = errors.Wrapf(err, "astistat: dialing %s failed", o.Addr)
+		return
+	}
+	return
+}
+
+// Close implements the io.Closer interface
+func (s *StatsDSink) Close() error {
+	return s.c.Close()
+}
+
+// HandleStats implements the StatsHandleFunc signature
+func (s *StatsDSink) HandleStats(stats []Stat) {
+	// Loop through metrics
+	buf := &bytes.Buffer{}
+	for _, m := range flattenStats(s.o.Prefix, stats) {
+		for _, f := range m.fields {
+			buf.WriteString(s.name(m, f) + ":" + strconv.FormatFloat(f.value, 'f', -1, 64) + "|g")
+			if s.o.DogStatsD {
+				if ts := s.tags(m); ts != "" {
+					buf.WriteString("|#" + ts)
+				}
+			}
+			buf.WriteByte('\n')
+		}
+	}
+
+	// Write
+	for _, p := range udpPackets(buf.Bytes()) {
+		if _, err := s.c.Write(p); err != nil {
+			astilog.Error(errors.Wrapf(err, "astistat: writing to %s failed", s.o.Addr))
+		}
+	}
+}
+
+func (s *StatsDSink) name(m sinkMetric, f sinkField) string {
+	if !s.o.DogStatsD && len(m.labels) > 0 {
+		m.name += "." + strings.Join(m.sortedLabels(".", metricNamePart), ".")
+	}
+	return m.fieldName(f)
+}
+
+func (s *StatsDSink) tags(m sinkMetric) string {
+	var ts []string
+	for k, v := range s.o.Tags {
+		ts = append(ts, k+":"+v)
+	}
+	sort.Strings(ts)
+	ts = append(ts, m.sortedLabels(":", func(s string) string { return s })...)
+	if m.unit != "" {
+		ts = append(ts, "unit:"+m.unit)
+	}
+	return strings.Join(ts, ",")
+}
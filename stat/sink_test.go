@@ -0,0 +1,227 @@
+package astistat
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astiws"
+	"github.com/stretchr/testify/assert"
+)
+
+var sinkTestStats = []Stat{
+	{StatMetadata: StatMetadata{Label: "Listen ratio", Labels: map[string]string{"stream": "1"}, Unit: "%"}, Value: 12.5},
+	{StatMetadata: StatMetadata{Label: "Latency"}, Value: SummaryValue{Count: 2, Max: 3, Mean: 2, Min: 1, P50: 1, P95: 3, P99: 3}},
+	{StatMetadata: StatMetadata{Label: "Ignored"}, Value: "ignored"},
+}
+
+func TestFlattenStats(t *testing.T) {
+	ms := flattenStats("app", sinkTestStats)
+	assert.Len(t, ms, 2)
+	assert.Equal(t, "app.listen_ratio", ms[0].name)
+	assert.Equal(t, "%", ms[0].unit)
+	assert.Equal(t, []sinkField{{key: sinkFieldValue, value: 12.5}}, ms[0].fields)
+	assert.Equal(t, "app.latency", ms[1].name)
+	assert.Len(t, ms[1].fields, 7)
+	assert.Equal(t, "app.latency.count", ms[1].fieldName(ms[1].fields[0]))
+
+	// Non finite values are skipped
+	ms = flattenStats("", []Stat{
+		{StatMetadata: StatMetadata{Label: "NaN"}, Value: math.NaN()},
+		{StatMetadata: StatMetadata{Label: "Inf"}, Value: math.Inf(1)},
+		{StatMetadata: StatMetadata{Label: "Summary"}, Value: SummaryValue{Count: 1, Max: 1, Mean: math.NaN(), Min: math.Inf(-1)}},
+	})
+	assert.Len(t, ms, 1)
+	assert.Equal(t, "summary", ms[0].name)
+	assert.Len(t, ms[0].fields, 5)
+}
+
+func TestStatsDSink(t *testing.T) {
+	// Listen
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer c.Close()
+
+	// Handle stats
+	s, err := NewStatsDSink(StatsDSinkOptions{
+		Addr:      c.LocalAddr().String(),
+		DogStatsD: true,
+		Prefix:    "app",
+		Tags:      map[string]string{"env": "test"},
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	s.HandleStats(sinkTestStats)
+
+	// Read
+	b := make([]byte, 2048)
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := c.ReadFrom(b)
+	assert.NoError(t, err)
+	ls := strings.Split(string(b[:n]), "\n")
+	assert.Len(t, ls, 8)
+	assert.Equal(t, "app.listen_ratio:12.5|g|#env:test,stream:1,unit:%", ls[0])
+	assert.Equal(t, "app.latency.p99:3|g|#env:test", ls[7])
+
+	// Labels are folded into names without DogStatsD
+	s2, err := NewStatsDSink(StatsDSinkOptions{
+		Addr:   c.LocalAddr().String(),
+		Prefix: "app",
+	})
+	assert.NoError(t, err)
+	defer s2.Close()
+	s2.HandleStats([]Stat{
+		{StatMetadata: StatMetadata{Label: "Listen ratio", Labels: map[string]string{"stream": "1", "Type": "a b"}}, Value: 12.5},
+		{StatMetadata: StatMetadata{Label: "Latency"}, Value: SummaryValue{P99: 3}},
+	})
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err = c.ReadFrom(b)
+	assert.NoError(t, err)
+	ls = strings.Split(string(b[:n]), "\n")
+	assert.Len(t, ls, 8)
+	assert.Equal(t, "app.listen_ratio.stream.1.type.a_b:12.5|g", ls[0])
+	assert.Equal(t, "app.latency.p99:3|g", ls[7])
+}
+
+func TestInfluxSink(t *testing.T) {
+	// Lines
+	s, err := NewInfluxSink(InfluxSinkOptions{
+		Tags: map[string]string{"host": "a b"},
+		URL:  "http://localhost",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "listen_ratio,host=a\\ b,stream=1,unit=% value=12.5 1000\nlatency,host=a\\ b count=2,max=3,mean=2,min=1,p50=1,p95=3,p99=3 1000\n", string(s.lines(sinkTestStats, time.Unix(0, 1000))))
+
+	// HTTP
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	s, err = NewInfluxSink(InfluxSinkOptions{URL: srv.URL + "/write?db=stats"})
+	assert.NoError(t, err)
+	s.HandleStats(sinkTestStats)
+	assert.Equal(t, 2, strings.Count(string(body), "\n"))
+
+	// Options
+	_, err = NewInfluxSink(InfluxSinkOptions{})
+	assert.Error(t, err)
+}
+
+func TestFileSink(t *testing.T) {
+	// Init
+	dir, err := ioutil.TempDir("", "astistat")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "stats.csv")
+
+	// CSV
+	s, err := NewFileSink(FileSinkOptions{
+		MaxBackups: 1,
+		MaxSize:    10,
+		Path:       p,
+	})
+	assert.NoError(t, err)
+	s.HandleStats(sinkTestStats[:1])
+	b, err := ioutil.ReadFile(p + ".1")
+	assert.NoError(t, err)
+	ls := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, ls, 2)
+	assert.Equal(t, "time,name,labels,unit,value", ls[0])
+	assert.True(t, strings.HasSuffix(ls[1], ",listen_ratio,stream=1,%,12.5"))
+	b, err = ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "time,name,labels,unit,value\n", string(b))
+	s.HandleStats(sinkTestStats[:1])
+	b, err = ioutil.ReadFile(p + ".1")
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(b)), "\n"), 2)
+	_, err = os.Stat(p + ".2")
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, s.Close())
+
+	// Failed rotation
+	p = filepath.Join(dir, "rotation.csv")
+	assert.NoError(t, os.MkdirAll(filepath.Join(p+".1", "blocker"), 0755))
+	s, err = NewFileSink(FileSinkOptions{
+		MaxBackups: 1,
+		MaxSize:    10,
+		Path:       p,
+	})
+	assert.NoError(t, err)
+	s.HandleStats(sinkTestStats[:1])
+	s.HandleStats(sinkTestStats[:1])
+	b, err = ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(b)), "\n"), 3)
+	assert.NoError(t, os.RemoveAll(p+".1"))
+	s.HandleStats(sinkTestStats[:1])
+	b, err = ioutil.ReadFile(p + ".1")
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(b)), "\n"), 4)
+	assert.NoError(t, s.Close())
+
+	// JSON lines
+	p = filepath.Join(dir, "stats.jsonl")
+	s, err = NewFileSink(FileSinkOptions{
+		Format: FileFormatJSONLines,
+		Path:   p,
+	})
+	assert.NoError(t, err)
+	s.HandleStats(sinkTestStats)
+	assert.NoError(t, s.Close())
+	b, err = ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	ls = strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, ls, 8)
+	var r fileSinkRow
+	assert.NoError(t, json.Unmarshal([]byte(ls[0]), &r))
+	assert.Equal(t, "listen_ratio", r.Name)
+	assert.Equal(t, map[string]string{"stream": "1"}, r.Labels)
+	assert.Equal(t, "%", r.Unit)
+	assert.Equal(t, 12.5, r.Value)
+}
+
+func TestWebsocketSink(t *testing.T) {
+	// Init
+	s := NewWebsocketSink(WebsocketSinkOptions{})
+	defer s.Close()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// Dial
+	c := astiws.NewClient(astiws.ClientConfiguration{})
+	defer c.Close()
+	assert.NoError(t, c.Dial("ws"+strings.TrimPrefix(srv.URL, "http")))
+	chanPayload := make(chan json.RawMessage, 1)
+	c.AddListener("astistat.stats", func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+		chanPayload <- payload
+		return nil
+	})
+	go c.Read()
+
+	// Wait for client to be registered
+	for idx := 0; idx < 100 && s.m.CountClients() == 0; idx++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Handle stats
+	s.HandleStats(sinkTestStats[:1])
+	select {
+	case p := <-chanPayload:
+		var ss []WebsocketStat
+		assert.NoError(t, json.Unmarshal(p, &ss))
+		assert.Equal(t, []WebsocketStat{{Label: "Listen ratio", Labels: map[string]string{"stream": "1"}, Name: "listen_ratio", Unit: "%", Value: 12.5}}, ss)
+	case <-time.After(time.Second):
+		t.Error("no payload received")
+	}
+}
@@ -0,0 +1,88 @@
+package astistat
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astiws"
+	"github.com/pkg/errors"
+)
+
+// WebsocketSinkOptions represents websocket sink options
+type WebsocketSinkOptions struct {
+	// EventName defaults to "astistat.stats"
+	EventName string
+	Manager   astiws.ManagerConfiguration
+	Prefix    string
+}
+
+// WebsocketSink represents a sink broadcasting stats to websocket clients
+// Each message's payload is a list of stats with their metadata, name and raw value
+type WebsocketSink struct {
+	m *astiws.Manager
+	o WebsocketSinkOptions
+}
+
+// WebsocketStat represents a stat sent to websocket clients
+type WebsocketStat struct {
+	Description string            `json:"description,omitempty"`
+	Label       string            `json:"label"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Name        string            `json:"name"`
+	Unit        string            `json:"unit,omitempty"`
+	Value       interface{}       `json:"value"`
+}
+
+// NewWebsocketSink creates a new websocket sink
+func NewWebsocketSink(o WebsocketSinkOptions) *WebsocketSink {
+	if o.EventName == "" {
+		o.EventName = "astistat.stats"
+	}
+	return &WebsocketSink{
+		m: astiws.NewManager(o.Manager),
+		o: o,
+	}
+}
+
+// Close implements the io.Closer interface
+func (s *WebsocketSink) Close() error {
+	return s.m.Close()
+}
+
+// ServeHTTP implements the http.Handler interface by upgrading the connection and registering the client
+func (s *WebsocketSink) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if err := s.m.ServeHTTP(rw, r, func(c *astiws.Client) error {
+		s.m.AutoRegisterClient(c)
+		c.AddListener(astiws.EventNameDisconnect, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+			s.m.AutoUnregisterClient(c)
+			return nil
+		})
+		return nil
+	}); err != nil {
+		astilog.Error(errors.Wrap(err, "astistat: serving websocket failed"))
+	}
+}
+
+// HandleStats implements the StatsHandleFunc signature
+func (s *WebsocketSink) HandleStats(stats []Stat) {
+	// Get payload
+	var ss []WebsocketStat
+	for _, v := range stats {
+		ss = append(ss, WebsocketStat{
+			Description: v.Description,
+			Label:       v.Label,
+			Labels:      v.Labels,
+			Name:        metricName(s.o.Prefix, v.Label),
+			Unit:        v.Unit,
+			Value:       v.Value,
+		})
+	}
+
+	// Broadcast
+	s.m.Loop(func(k interface{}, c *astiws.Client) {
+		if err := c.Write(s.o.EventName, ss); err != nil {
+			astilog.Error(errors.Wrap(err, "astistat: writing to websocket client failed"))
+		}
+	})
+}
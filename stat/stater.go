@@ -8,13 +8,26 @@ import (
 
 // Stater is an object that can compute and handle stats
 type Stater struct {
-	cancel context.CancelFunc
-	ctx    context.Context
-	fn     StatsHandleFunc
-	oStart *sync.Once
-	oStop  *sync.Once
-	period time.Duration
-	ss     []stat
+	cancel    context.CancelFunc
+	ctx       context.Context
+	h         map[StatID][]StatPoint
+	id        StatID
+	isStarted bool
+	m         *sync.Mutex // Locks h, id, isStarted, rs, ruleID and ss
+	o         StaterOptions
+	oStart    *sync.Once
+	oStop     *sync.Once
+	rs        map[AlertRuleID]*alertRule
+	ruleID    AlertRuleID
+	ss        []stat
+}
+
+// StaterOptions represents stater options
+type StaterOptions struct {
+	HandleFunc StatsHandleFunc
+	// HistoryRetention is the duration during which stat values are kept in history. 0 disables history.
+	HistoryRetention time.Duration
+	Period           time.Duration
 }
 
 // Stat represents a stat
@@ -30,9 +43,14 @@ type StatsHandleFunc func(stats []Stat)
 type StatMetadata struct {
 	Description string
 	Label       string
-	Unit        string
+	// Labels are the stat dimensions, e.g. stream=id
+	Labels map[string]string
+	Unit   string
 }
 
+// StatID represents the id of a stat added to a stater
+type StatID uint64
+
 // StatHandler represents a stat handler
 type StatHandler interface {
 	Start()
@@ -41,17 +59,28 @@ type StatHandler interface {
 }
 
 type stat struct {
-	h StatHandler
-	m StatMetadata
+	h  StatHandler
+	id StatID
+	m  StatMetadata
 }
 
 // NewStater creates a new stater
 func NewStater(period time.Duration, fn StatsHandleFunc) *Stater {
+	return NewStaterWithOptions(StaterOptions{
+		HandleFunc: fn,
+		Period:     period,
+	})
+}
+
+// NewStaterWithOptions creates a new stater with options
+func NewStaterWithOptions(o StaterOptions) *Stater {
 	return &Stater{
-		fn:     fn,
+		h:      make(map[StatID][]StatPoint),
+		m:      &sync.Mutex{},
+		o:      o,
 		oStart: &sync.Once{},
 		oStop:  &sync.Once{},
-		period: period,
+		rs:     make(map[AlertRuleID]*alertRule),
 	}
 }
 
@@ -71,14 +100,17 @@ func (s *Stater) Start(ctx context.Context) {
 		s.oStop = &sync.Once{}
 
 		// Start stats
+		s.m.Lock()
+		s.isStarted = true
 		for _, v := range s.ss {
 			v.h.Start()
 		}
+		s.m.Unlock()
 
 		// Execute the rest in a go routine
 		go func() {
 			// Create ticker
-			t := time.NewTicker(s.period)
+			t := time.NewTicker(s.o.Period)
 			defer t.Stop()
 
 			// Loop
@@ -93,6 +125,7 @@ func (s *Stater) Start(ctx context.Context) {
 
 					// Loop through stats
 					var stats []Stat
+					s.m.Lock()
 					for _, v := range s.ss {
 						stats = append(stats, Stat{
 							StatMetadata: v.m,
@@ -100,13 +133,28 @@ func (s *Stater) Start(ctx context.Context) {
 						})
 					}
 
+					// Record history and evaluate alert rules
+					s.record(now, stats)
+					es := s.evaluate(now, stats)
+					s.m.Unlock()
+
 					// Handle stats
-					go s.fn(stats)
+					if s.o.HandleFunc != nil {
+						go s.o.HandleFunc(stats)
+					}
+
+					// Handle alert events
+					for _, e := range es {
+						e.fn(e.e)
+					}
 				case <-s.ctx.Done():
 					// Stop stats
+					s.m.Lock()
+					s.isStarted = false
 					for _, v := range s.ss {
 						v.h.Stop()
 					}
+					s.m.Unlock()
 					return
 				}
 			}
@@ -114,12 +162,59 @@ func (s *Stater) Start(ctx context.Context) {
 	})
 }
 
-// AddStat adds a stat
-func (s *Stater) AddStat(m StatMetadata, h StatHandler) {
+// AddStat adds a stat and returns its id
+// It can be called while the stater is running, in which case the stat is started right away
+func (s *Stater) AddStat(m StatMetadata, h StatHandler) StatID {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Add stat
+	s.id++
 	s.ss = append(s.ss, stat{
-		h: h,
-		m: m,
+		h:  h,
+		id: s.id,
+		m:  m,
 	})
+
+	// Start stat
+	if s.isStarted {
+		h.Start()
+	}
+	return s.id
+}
+
+// RemoveStat removes a stat as well as its history and alert rules
+// It can be called while the stater is running, in which case the stat is stopped right away. Alert rules that are
+// firing are resolved.
+func (s *Stater) RemoveStat(id StatID) {
+	// Lock
+	s.m.Lock()
+
+	// Loop through stats
+	var es []alertEventHandler
+	for idx, v := range s.ss {
+		if v.id == id {
+			// Stop stat
+			if s.isStarted {
+				v.h.Stop()
+			}
+
+			// Remove stat
+			s.ss = append(s.ss[:idx], s.ss[idx+1:]...)
+			delete(s.h, id)
+			es = s.removeAlertRules(v)
+			break
+		}
+	}
+
+	// Unlock
+	s.m.Unlock()
+
+	// Handle alert events
+	for _, e := range es {
+		e.fn(e.e)
+	}
 }
 
 // Stop stops the stater
@@ -138,6 +233,8 @@ func (s *Stater) Stop() {
 
 // StatsMetadata returns the stats metadata
 func (s *Stater) StatsMetadata() (ms []StatMetadata) {
+	s.m.Lock()
+	defer s.m.Unlock()
 	ms = []StatMetadata{}
 	for _, v := range s.ss {
 		ms = append(ms, v.m)
@@ -145,6 +242,55 @@ func (s *Stater) StatsMetadata() (ms []StatMetadata) {
 	return
 }
 
+// StatGroup represents a group of stats sharing the same labels, that can be removed at once
+// For instance, a group can be created for each connection and removed once the connection is closed
+type StatGroup struct {
+	ids    []StatID
+	labels map[string]string
+	m      *sync.Mutex // Locks ids
+	s      *Stater
+}
+
+// NewGroup creates a new group of stats sharing the same labels
+func (s *Stater) NewGroup(labels map[string]string) *StatGroup {
+	return &StatGroup{
+		labels: labels,
+		m:      &sync.Mutex{},
+		s:      s,
+	}
+}
+
+// AddStat adds a stat to the group
+// Group labels are merged with the stat labels, the latter taking precedence
+func (g *StatGroup) AddStat(m StatMetadata, h StatHandler) StatID {
+	// Merge labels
+	ls := make(map[string]string)
+	for k, v := range g.labels {
+		ls[k] = v
+	}
+	for k, v := range m.Labels {
+		ls[k] = v
+	}
+	m.Labels = ls
+
+	// Add stat
+	id := g.s.AddStat(m, h)
+	g.m.Lock()
+	g.ids = append(g.ids, id)
+	g.m.Unlock()
+	return id
+}
+
+// Remove removes all the stats of the group
+func (g *StatGroup) Remove() {
+	g.m.Lock()
+	defer g.m.Unlock()
+	for _, id := range g.ids {
+		g.s.RemoveStat(id)
+	}
+	g.ids = nil
+}
+
 // StatHandlerWithoutStart represents a stat handler that doesn't have to start or stop
 type StatHandlerWithoutStart func(delta time.Duration) interface{}
 
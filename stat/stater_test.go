@@ -0,0 +1,156 @@
+package astistat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStater(t *testing.T) {
+	// Init
+	chanStats := make(chan []Stat, 10)
+	s := NewStater(5*time.Millisecond, func(stats []Stat) { chanStats <- stats })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.AddStat(StatMetadata{Label: "a"}, StatHandlerWithoutStart(func(delta time.Duration) interface{} { return 1 }))
+	s.Start(ctx)
+	defer s.Stop()
+	waitFor := func(fn func(stats []Stat) bool) (stats []Stat) {
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case stats = <-chanStats:
+				if fn(stats) {
+					return
+				}
+			case <-timeout:
+				t.Fatal("expected stats weren't received")
+			}
+		}
+	}
+	labels := func(stats []Stat) (ls []string) {
+		for _, st := range stats {
+			ls = append(ls, st.Label)
+		}
+		return
+	}
+	assert.Equal(t, []string{"a"}, labels(waitFor(func([]Stat) bool { return true })))
+
+	// Add while running
+	i := NewIncrementStat()
+	id := s.AddStat(StatMetadata{Label: "b"}, i)
+	i.Add(1)
+	waitFor(func(stats []Stat) bool { return len(stats) == 2 })
+
+	// Group
+	g := s.NewGroup(map[string]string{"stream": "1", "type": "video"})
+	g.AddStat(StatMetadata{Label: "c", Labels: map[string]string{"type": "audio"}}, NewGaugeStat())
+	stats := waitFor(func(stats []Stat) bool { return len(stats) == 3 })
+	assert.Equal(t, []string{"a", "b", "c"}, labels(stats))
+	assert.Equal(t, map[string]string{"stream": "1", "type": "audio"}, stats[2].Labels)
+
+	// Remove while running
+	s.RemoveStat(id)
+	g.Remove()
+	assert.Len(t, s.StatsMetadata(), 1)
+	assert.Equal(t, []string{"a"}, labels(waitFor(func(stats []Stat) bool { return len(stats) == 1 })))
+}
+
+func TestStater_Alerts(t *testing.T) {
+	// Init
+	g := NewGaugeStat()
+	s := NewStaterWithOptions(StaterOptions{Period: 2 * time.Millisecond})
+	id := s.AddStat(StatMetadata{Label: "gauge"}, g)
+	chanFire := make(chan AlertEvent, 10)
+	chanResolve := make(chan AlertEvent, 10)
+	_, err := s.AddAlertRule(AlertRule{Condition: "abve", StatID: id})
+	assert.Error(t, err)
+	_, err = s.AddAlertRule(AlertRule{
+		Condition: AlertConditionAbove,
+		For:       10 * time.Millisecond,
+		Name:      "high",
+		OnFire:    func(e AlertEvent) { chanFire <- e },
+		OnResolve: func(e AlertEvent) { chanResolve <- e },
+		StatID:    id,
+		Threshold: 5,
+	})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	defer s.Stop()
+
+	// Fire
+	g.Set(10)
+	var e AlertEvent
+	select {
+	case e = <-chanFire:
+	case <-time.After(time.Second):
+		t.Fatal("alert didn't fire")
+	}
+	assert.Equal(t, "high", e.Rule.Name)
+	assert.Equal(t, "gauge", e.Stat.Label)
+	assert.Equal(t, float64(10), e.Value)
+	assert.True(t, e.At.Sub(e.Since) >= 10*time.Millisecond)
+
+	// Resolve
+	g.Set(1)
+	select {
+	case e = <-chanResolve:
+	case <-time.After(time.Second):
+		t.Fatal("alert didn't resolve")
+	}
+	assert.Equal(t, float64(1), e.Value)
+	assert.Len(t, chanFire, 0)
+
+	// Remove stat while firing
+	g.Set(10)
+	select {
+	case <-chanFire:
+	case <-time.After(time.Second):
+		t.Fatal("alert didn't fire")
+	}
+	s.RemoveStat(id)
+	select {
+	case e = <-chanResolve:
+	case <-time.After(time.Second):
+		t.Fatal("alert wasn't resolved on removal")
+	}
+	assert.Equal(t, "gauge", e.Stat.Label)
+	assert.Equal(t, float64(10), e.Value)
+	s.m.Lock()
+	assert.Empty(t, s.rs)
+	s.m.Unlock()
+}
+
+func TestStater_History(t *testing.T) {
+	// Init
+	s := NewStaterWithOptions(StaterOptions{HistoryRetention: 20 * time.Millisecond})
+	id := s.AddStat(StatMetadata{Label: "gauge"}, NewGaugeStat())
+	t0 := time.Unix(1000, 0)
+
+	// Record
+	s.m.Lock()
+	for i := 0; i < 5; i++ {
+		s.record(t0.Add(time.Duration(i)*10*time.Millisecond), []Stat{{Value: float64(i)}})
+	}
+	s.m.Unlock()
+
+	// Retention
+	ps := s.History(id, time.Time{}, time.Time{})
+	assert.Equal(t, []StatPoint{
+		{At: t0.Add(20 * time.Millisecond), Value: float64(2)},
+		{At: t0.Add(30 * time.Millisecond), Value: float64(3)},
+		{At: t0.Add(40 * time.Millisecond), Value: float64(4)},
+	}, ps)
+
+	// Bounds
+	assert.Equal(t, ps[1:2], s.History(id, t0.Add(25*time.Millisecond), t0.Add(35*time.Millisecond)))
+	assert.Empty(t, s.History(id, t0.Add(time.Second), time.Time{}))
+
+	// Remove
+	s.RemoveStat(id)
+	assert.Empty(t, s.History(id, time.Time{}, time.Time{}))
+}
@@ -0,0 +1,145 @@
+package astistat
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SummaryValue represents the value of a summary or histogram stat over a period
+type SummaryValue struct {
+	Count int64
+	Max   float64
+	Mean  float64
+	Min   float64
+	P50   float64
+	P95   float64
+	P99   float64
+}
+
+// SummaryStat is an object capable of computing min, max, mean and percentiles of values added over each period
+// Min, max and mean are exact. Percentiles are exact as long as fewer than MaxSamples values are added during the
+// period, and computed on a uniform sample of the values otherwise.
+type SummaryStat struct {
+	count     int64
+	isStarted bool
+	m         *sync.Mutex
+	max       float64
+	maxSize   int
+	min       float64
+	r         *rand.Rand
+	samples   []float64
+	sum       float64
+}
+
+// SummaryStatOptions represents summary stat options
+type SummaryStatOptions struct {
+	// MaxSamples is the max number of values kept to compute percentiles. Defaults to 10000.
+	MaxSamples int
+}
+
+// NewSummaryStat creates a new summary stat
+func NewSummaryStat(o SummaryStatOptions) *SummaryStat {
+	if o.MaxSamples <= 0 {
+		o.MaxSamples = 10000
+	}
+	return &SummaryStat{
+		m:       &sync.Mutex{},
+		maxSize: o.MaxSamples,
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Add adds a value
+func (s *SummaryStat) Add(v float64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.isStarted {
+		return
+	}
+
+	// Update min, max and mean
+	s.count++
+	s.sum += v
+	if s.count == 1 || v < s.min {
+		s.min = v
+	}
+	if s.count == 1 || v > s.max {
+		s.max = v
+	}
+
+	// Reservoir sampling
+	if len(s.samples) < s.maxSize {
+		s.samples = append(s.samples, v)
+	} else if idx := s.r.Int63n(s.count); idx < int64(s.maxSize) {
+		s.samples[idx] = v
+	}
+}
+
+// AddDuration adds a duration expressed in milliseconds
+func (s *SummaryStat) AddDuration(d time.Duration) {
+	s.Add(float64(d) / float64(time.Millisecond))
+}
+
+// Start implements the StatHandler interface
+func (s *SummaryStat) Start() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.reset()
+	s.isStarted = true
+}
+
+// Stop implements the StatHandler interface
+func (s *SummaryStat) Stop() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.isStarted = false
+}
+
+// Assumes the lock is held
+func (s *SummaryStat) reset() {
+	s.count = 0
+	s.max = 0
+	s.min = 0
+	s.samples = s.samples[:0]
+	s.sum = 0
+}
+
+// Value implements the StatHandler interface
+func (s *SummaryStat) Value(delta time.Duration) interface{} {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Compute value
+	v := SummaryValue{
+		Count: s.count,
+		Max:   s.max,
+		Min:   s.min,
+	}
+	if s.count > 0 {
+		v.Mean = s.sum / float64(s.count)
+		sort.Float64s(s.samples)
+		v.P50 = percentile(s.samples, 50)
+		v.P95 = percentile(s.samples, 95)
+		v.P99 = percentile(s.samples, 99)
+	}
+
+	// Reset
+	s.reset()
+	return v
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(vs []float64, p float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p/100*float64(len(vs)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return vs[idx]
+}
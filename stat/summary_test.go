@@ -0,0 +1,81 @@
+package astistat
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummaryStat(t *testing.T) {
+	// Not started
+	s := NewSummaryStat(SummaryStatOptions{})
+	s.Add(1)
+	assert.Equal(t, SummaryValue{}, s.Value(time.Second))
+
+	// Started
+	s.Start()
+	for idx := 1; idx <= 100; idx++ {
+		s.Add(float64(idx))
+	}
+	assert.Equal(t, SummaryValue{
+		Count: 100,
+		Max:   100,
+		Mean:  50.5,
+		Min:   1,
+		P50:   50,
+		P95:   95,
+		P99:   99,
+	}, s.Value(time.Second))
+	assert.Equal(t, SummaryValue{}, s.Value(time.Second))
+
+	// Sampling
+	s = NewSummaryStat(SummaryStatOptions{MaxSamples: 10})
+	s.Start()
+	for idx := 1; idx <= 100; idx++ {
+		s.Add(float64(idx))
+	}
+	v := s.Value(time.Second).(SummaryValue)
+	assert.Equal(t, int64(100), v.Count)
+	assert.Equal(t, float64(1), v.Min)
+	assert.Equal(t, float64(100), v.Max)
+	assert.True(t, v.P50 >= 1 && v.P50 <= 100)
+}
+
+func TestHistogramStat(t *testing.T) {
+	s := NewHistogramStat([]float64{50, 10, 100})
+	s.Start()
+	for idx := 1; idx <= 200; idx++ {
+		s.Add(float64(idx) / 2)
+	}
+	v := s.Value(time.Second).(HistogramValue)
+	assert.Equal(t, []HistogramBucket{
+		{Count: 20, UpperBound: 10},
+		{Count: 80, UpperBound: 50},
+		{Count: 100, UpperBound: 100},
+		{Count: 0, UpperBound: math.Inf(1)},
+	}, v.Buckets)
+	assert.Equal(t, int64(200), v.Count)
+	assert.Equal(t, 0.5, v.Min)
+	assert.Equal(t, float64(100), v.Max)
+	assert.Equal(t, 50.25, v.Mean)
+	assert.Equal(t, float64(50), v.P50)
+	assert.Equal(t, float64(95), v.P95)
+	assert.Equal(t, float64(99), v.P99)
+}
+
+func TestGaugeAndCounterStats(t *testing.T) {
+	g := NewGaugeStat()
+	g.Set(2)
+	g.Add(-0.5)
+	assert.Equal(t, 1.5, g.Value(time.Second))
+	assert.Equal(t, 1.5, g.Value(time.Second))
+
+	c := NewCounterStat()
+	c.Add(2)
+	c.Add(-1)
+	c.Add(3)
+	assert.Equal(t, int64(5), c.Value(time.Second))
+	assert.Equal(t, int64(5), c.Value(time.Second))
+}
@@ -0,0 +1,325 @@
+package astisync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	astierror "github.com/asticode/go-astitools/error"
+)
+
+// Slow subscriber policies
+const (
+	// Publish blocks until the subscriber has room in its buffer
+	SlowSubscriberPolicyBlock = "block"
+	// The subscriber is closed and its Err method returns ErrSlowSubscriber
+	SlowSubscriberPolicyDisconnect = "disconnect"
+	// The message is dropped for this subscriber only
+	SlowSubscriberPolicyDrop = "drop"
+)
+
+// Broker errors
+var (
+	ErrBrokerClosed   = errors.New("astisync: broker is closed")
+	ErrSlowSubscriber = errors.New("astisync: subscriber was too slow")
+)
+
+// BrokerMessage represents a message published in a broker
+type BrokerMessage struct {
+	Payload interface{}
+	Topic   string
+}
+
+// BrokerOptions represents broker options
+type BrokerOptions struct {
+	// BufferSize is the default subscriber buffer size. Defaults to 100.
+	BufferSize int
+	// DisableReplay prevents the broker from keeping the last value published on each topic, which is useful when
+	// topics are short lived, e.g. per connection topics
+	DisableReplay bool
+	// SlowSubscriberPolicy is the default slow subscriber policy. Defaults to SlowSubscriberPolicyDrop.
+	SlowSubscriberPolicy string
+}
+
+// Broker represents a topic based publish/subscribe broker
+// Topics are dot-separated. In patterns, "*" matches exactly one segment and ">" matches one or more trailing segments.
+// Unless replay is disabled, the last value published on each topic is kept so that it can be replayed to late
+// subscribers until the topic is forgotten.
+type Broker struct {
+	closed bool
+	last   map[string]BrokerMessage
+	m      *sync.RWMutex // Locks closed, last and ss
+	o      BrokerOptions
+	ss     map[*Subscriber]bool
+}
+
+// NewBroker creates a new broker
+func NewBroker(o BrokerOptions) *Broker {
+	if o.BufferSize <= 0 {
+		o.BufferSize = 100
+	}
+	if o.SlowSubscriberPolicy == "" {
+		o.SlowSubscriberPolicy = SlowSubscriberPolicyDrop
+	}
+	return &Broker{
+		last: make(map[string]BrokerMessage),
+		m:    &sync.RWMutex{},
+		o:    o,
+		ss:   make(map[*Subscriber]bool),
+	}
+}
+
+// SubscribeOptions represents subscribe options
+type SubscribeOptions struct {
+	// BufferSize defaults to the broker's buffer size
+	BufferSize int
+	// Replay sends the last value published on each topic matching the pattern upon subscribing. It has no effect if
+	// replay is disabled in the broker.
+	Replay bool
+	// SlowSubscriberPolicy defaults to the broker's slow subscriber policy
+	SlowSubscriberPolicy string
+}
+
+// Subscriber represents a broker subscriber
+type Subscriber struct {
+	b       *Broker
+	c       chan BrokerMessage
+	cDone   chan bool
+	closed  bool
+	dropped uint64
+	err     error
+	m       *sync.RWMutex // Locks closed and err
+	o       SubscribeOptions
+	oClose  *sync.Once
+	pattern []string
+}
+
+// Subscribe subscribes to topics matching the pattern
+// The subscriber is closed when ctx is done
+func (b *Broker) Subscribe(ctx context.Context, pattern string, o SubscribeOptions) (s *Subscriber, err error) {
+	// Default options
+	if o.BufferSize <= 0 {
+		o.BufferSize = b.o.BufferSize
+	}
+	if o.SlowSubscriberPolicy == "" {
+		o.SlowSubscriberPolicy = b.o.SlowSubscriberPolicy
+	}
+
+	// Create subscriber
+	s = &Subscriber{
+		b:       b,
+		c:       make(chan BrokerMessage, o.BufferSize),
+		cDone:   make(chan bool),
+		m:       &sync.RWMutex{},
+		o:       o,
+		oClose:  &sync.Once{},
+		pattern: splitTopic(pattern),
+	}
+
+	// Lock
+	b.m.Lock()
+
+	// Broker is closed
+	if b.closed {
+		b.m.Unlock()
+		return nil, ErrBrokerClosed
+	}
+
+	// Add subscriber
+	b.ss[s] = true
+
+	// Replay
+	if o.Replay {
+		for t, m := range b.last {
+			if s.matches(t) {
+				select {
+				case s.c <- m:
+				default:
+				}
+			}
+		}
+	}
+	b.m.Unlock()
+
+	// Handle context
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.close(nil)
+		case <-s.cDone:
+		}
+	}()
+	return
+}
+
+// Publish publishes a payload on a topic
+// The message is delivered to all matching subscribers: subscribers with the block policy are handled last and may
+// block it until ctx is done, in which case the errors are returned as an astierror.Multiple. Messages published by the
+// same goroutine are received in order.
+func (b *Broker) Publish(ctx context.Context, topic string, payload interface{}) error {
+	// Lock
+	b.m.Lock()
+
+	// Broker is closed
+	if b.closed {
+		b.m.Unlock()
+		return ErrBrokerClosed
+	}
+
+	// Store last value
+	m := BrokerMessage{
+		Payload: payload,
+		Topic:   topic,
+	}
+	if !b.o.DisableReplay {
+		b.last[topic] = m
+	}
+
+	// Get subscribers
+	var blocking, nonBlocking []*Subscriber
+	for s := range b.ss {
+		if s.matches(topic) {
+			if s.o.SlowSubscriberPolicy == SlowSubscriberPolicyBlock {
+				blocking = append(blocking, s)
+			} else {
+				nonBlocking = append(nonBlocking, s)
+			}
+		}
+	}
+	b.m.Unlock()
+
+	// Send
+	var errs []error
+	for _, s := range append(nonBlocking, blocking...) {
+		if err := s.send(ctx, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return astierror.NewMultiple(errs)
+	}
+	return nil
+}
+
+// Forget removes the last value published on a topic so that it is not replayed anymore
+func (b *Broker) Forget(topic string) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	delete(b.last, topic)
+}
+
+// Close closes the broker and all its subscribers
+func (b *Broker) Close() {
+	// Lock
+	b.m.Lock()
+	b.closed = true
+	var ss []*Subscriber
+	for s := range b.ss {
+		ss = append(ss, s)
+	}
+	b.m.Unlock()
+
+	// Close subscribers
+	for _, s := range ss {
+		s.close(ErrBrokerClosed)
+	}
+}
+
+func splitTopic(t string) []string {
+	return strings.Split(t, ".")
+}
+
+func (s *Subscriber) matches(topic string) bool {
+	ts := splitTopic(topic)
+	for idx, p := range s.pattern {
+		if p == ">" {
+			return len(ts) > idx
+		} else if idx >= len(ts) || (p != "*" && p != ts[idx]) {
+			return false
+		}
+	}
+	return len(ts) == len(s.pattern)
+}
+
+func (s *Subscriber) send(ctx context.Context, m BrokerMessage) error {
+	// Lock
+	s.m.RLock()
+
+	// Subscriber is closed
+	if s.closed {
+		s.m.RUnlock()
+		return nil
+	}
+
+	// Send
+	switch s.o.SlowSubscriberPolicy {
+	case SlowSubscriberPolicyBlock:
+		defer s.m.RUnlock()
+		select {
+		case s.c <- m:
+		case <-s.cDone:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	case SlowSubscriberPolicyDisconnect:
+		select {
+		case s.c <- m:
+			s.m.RUnlock()
+		default:
+			s.m.RUnlock()
+			s.close(ErrSlowSubscriber)
+		}
+	default:
+		select {
+		case s.c <- m:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+		s.m.RUnlock()
+	}
+	return nil
+}
+
+// C returns the channel messages are received on
+// It is closed when the subscriber is closed
+func (s *Subscriber) C() <-chan BrokerMessage {
+	return s.c
+}
+
+// Close unsubscribes and closes the subscriber
+func (s *Subscriber) Close() {
+	s.close(nil)
+}
+
+func (s *Subscriber) close(err error) {
+	s.oClose.Do(func() {
+		// Unblock senders
+		close(s.cDone)
+
+		// Remove from broker
+		s.b.m.Lock()
+		delete(s.b.ss, s)
+		s.b.m.Unlock()
+
+		// Close
+		s.m.Lock()
+		s.closed = true
+		s.err = err
+		close(s.c)
+		s.m.Unlock()
+	})
+}
+
+// Dropped returns the number of messages dropped because the subscriber was too slow
+func (s *Subscriber) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Err returns why the subscriber has been closed, if it wasn't closed by the user or by its context
+func (s *Subscriber) Err() error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return s.err
+}
@@ -0,0 +1,147 @@
+package astisync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	astierror "github.com/asticode/go-astitools/error"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriber_Matches(t *testing.T) {
+	for _, v := range []struct {
+		match   bool
+		pattern string
+		topic   string
+	}{
+		{match: true, pattern: "a.b", topic: "a.b"},
+		{match: false, pattern: "a.b", topic: "a.c"},
+		{match: false, pattern: "a.b", topic: "a.b.c"},
+		{match: true, pattern: "a.*", topic: "a.b"},
+		{match: false, pattern: "a.*", topic: "a.b.c"},
+		{match: true, pattern: "*.b", topic: "a.b"},
+		{match: true, pattern: "a.>", topic: "a.b.c"},
+		{match: false, pattern: "a.>", topic: "a"},
+		{match: true, pattern: ">", topic: "a"},
+	} {
+		s := &Subscriber{pattern: splitTopic(v.pattern)}
+		assert.Equal(t, v.match, s.matches(v.topic), "pattern %s, topic %s", v.pattern, v.topic)
+	}
+}
+
+func TestBroker(t *testing.T) {
+	// Init
+	b := NewBroker(BrokerOptions{BufferSize: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Replay
+	assert.NoError(t, b.Publish(ctx, "a.1", 1))
+	s1, err := b.Subscribe(ctx, "a.*", SubscribeOptions{Replay: true})
+	assert.NoError(t, err)
+	assert.Equal(t, BrokerMessage{Payload: 1, Topic: "a.1"}, <-s1.C())
+
+	// Drop
+	assert.NoError(t, b.Publish(ctx, "a.1", 2))
+	assert.NoError(t, b.Publish(ctx, "a.2", 3))
+	assert.NoError(t, b.Publish(ctx, "b.1", 4))
+	assert.Equal(t, uint64(1), s1.Dropped())
+	assert.Equal(t, BrokerMessage{Payload: 2, Topic: "a.1"}, <-s1.C())
+
+	// Disconnect
+	s2, err := b.Subscribe(ctx, "a.>", SubscribeOptions{SlowSubscriberPolicy: SlowSubscriberPolicyDisconnect})
+	assert.NoError(t, err)
+	assert.NoError(t, b.Publish(ctx, "a.1", 5))
+	assert.NoError(t, b.Publish(ctx, "a.1", 6))
+	assert.Equal(t, ErrSlowSubscriber, s2.Err())
+	assert.Equal(t, BrokerMessage{Payload: 5, Topic: "a.1"}, <-s2.C())
+	_, ok := <-s2.C()
+	assert.False(t, ok)
+
+	// Block
+	s1.Close()
+	s3, err := b.Subscribe(ctx, "c", SubscribeOptions{SlowSubscriberPolicy: SlowSubscriberPolicyBlock})
+	assert.NoError(t, err)
+	assert.NoError(t, b.Publish(ctx, "c", 7))
+	pctx, pcancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer pcancel()
+	assert.Equal(t, astierror.NewMultiple([]error{context.DeadlineExceeded}), b.Publish(pctx, "c", 8))
+	chanDone := make(chan error)
+	go func() { chanDone <- b.Publish(ctx, "c", 9) }()
+	assert.Equal(t, 7, (<-s3.C()).Payload)
+	assert.NoError(t, <-chanDone)
+	assert.Equal(t, 9, (<-s3.C()).Payload)
+
+	// Context
+	sctx, scancel := context.WithCancel(ctx)
+	s4, err := b.Subscribe(sctx, "d", SubscribeOptions{})
+	assert.NoError(t, err)
+	scancel()
+	_, ok = <-s4.C()
+	assert.False(t, ok)
+	assert.NoError(t, s4.Err())
+
+	// Close
+	b.Close()
+	_, ok = <-s3.C()
+	assert.False(t, ok)
+	assert.Equal(t, ErrBrokerClosed, s3.Err())
+	assert.Equal(t, ErrBrokerClosed, b.Publish(ctx, "c", 10))
+	s5, err := b.Subscribe(ctx, "c", SubscribeOptions{})
+	assert.Equal(t, ErrBrokerClosed, err)
+	assert.Nil(t, s5)
+}
+
+func TestBroker_PublishToAllSubscribers(t *testing.T) {
+	// Init
+	b := NewBroker(BrokerOptions{BufferSize: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var blocking, nonBlocking []*Subscriber
+	for idx := 0; idx < 3; idx++ {
+		s, err := b.Subscribe(ctx, "a", SubscribeOptions{SlowSubscriberPolicy: SlowSubscriberPolicyBlock})
+		assert.NoError(t, err)
+		blocking = append(blocking, s)
+		s, err = b.Subscribe(ctx, "a", SubscribeOptions{})
+		assert.NoError(t, err)
+		nonBlocking = append(nonBlocking, s)
+	}
+
+	// Fill buffers
+	assert.NoError(t, b.Publish(ctx, "a", 1))
+
+	// Expired blocking subscribers don't prevent other subscribers from receiving the message
+	pctx, pcancel := context.WithCancel(ctx)
+	pcancel()
+	err := b.Publish(pctx, "a", 2)
+	assert.Equal(t, astierror.NewMultiple([]error{context.Canceled, context.Canceled, context.Canceled}), err)
+	for _, s := range nonBlocking {
+		assert.Equal(t, uint64(1), s.Dropped())
+	}
+	for _, s := range blocking {
+		assert.Equal(t, 1, (<-s.C()).Payload)
+	}
+}
+
+func TestBroker_Replay(t *testing.T) {
+	// Forget
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := NewBroker(BrokerOptions{})
+	assert.NoError(t, b.Publish(ctx, "a", 1))
+	assert.NoError(t, b.Publish(ctx, "b", 2))
+	b.Forget("a")
+	s, err := b.Subscribe(ctx, "*", SubscribeOptions{Replay: true})
+	assert.NoError(t, err)
+	assert.Equal(t, BrokerMessage{Payload: 2, Topic: "b"}, <-s.C())
+	assert.Len(t, s.C(), 0)
+
+	// Disabled
+	b = NewBroker(BrokerOptions{DisableReplay: true})
+	assert.NoError(t, b.Publish(ctx, "a", 1))
+	s, err = b.Subscribe(ctx, "*", SubscribeOptions{Replay: true})
+	assert.NoError(t, err)
+	assert.Len(t, s.C(), 0)
+	assert.Len(t, b.last, 0)
+}
@@ -1,9 +1,13 @@
 package astisync
 
 import (
+	"container/heap"
 	"context"
+	"errors"
 	"sync"
+	"time"
 
+	"github.com/asticode/go-astitools/stat"
 	astiworker "github.com/asticode/go-astitools/worker"
 )
 
@@ -13,42 +17,96 @@ const (
 	FILOOrder = "filo"
 )
 
+// Full policies
+const (
+	// Add blocks until there's room in the buffer or the chan is stopped
+	FullPolicyBlock = "block"
+	// The oldest func in the buffer is dropped
+	FullPolicyDropOldest = "drop-oldest"
+	// The func being added is dropped
+	FullPolicyDropNewest = "drop-newest"
+	// Add returns ErrChanFull
+	FullPolicyError = "error"
+)
+
+// ErrChanFull is returned when adding a func to a full chan with the error policy
+var ErrChanFull = errors.New("astisync: chan is full")
+
 // Chan is an object capable of doing stuff in a specific order without blocking when adding new items
-// in the @
+// in the @, unless its capacity has been reached and the full policy is FullPolicyBlock
+// Funcs with a higher priority are executed first, and funcs with a "not before" timestamp are only executed once it
+// has been reached. Funcs with the same priority are executed in the chan order.
 type Chan struct {
-	cancel context.CancelFunc
-	c      *sync.Cond
-	ctx    context.Context
-	fs     []func()
-	mc     *sync.Mutex // Locks ctx
-	mf     *sync.Mutex // Locks fs
-	o      ChanOptions
-	oStart *sync.Once
-	oStop  *sync.Once
+	cancel      context.CancelFunc
+	c           *sync.Cond
+	cSpace      *sync.Cond
+	ctx         context.Context
+	delayed     *chanHeap
+	keys        map[string]*chanKey
+	mc          *sync.Mutex // Locks ctx
+	mf          *sync.Mutex // Locks delayed, keys, numPending, ready, seq and stopped
+	numPending  int
+	o           ChanOptions
+	oStart      *sync.Once
+	oStop       *sync.Once
+	ready       *chanHeap
+	seq         uint64
+	statDropped *astistat.IncrementStat
+	statWork    *astistat.DurationRatioStat
+	stopped     bool
+}
+
+// chanKey represents the state of a key
+// In order for pop to be cheap, the ready heap only contains the first func of each key that is not being executed,
+// while other funcs of the key wait in the pending heap.
+type chanKey struct {
+	busy    bool
+	head    *chanItem
+	pending *chanHeap
 }
 
 // ChanOptions are Chan options
 type ChanOptions struct {
-	Order string
+	// Capacity is the max number of funcs waiting in the buffer. 0 means unbounded.
+	Capacity int
+	// Concurrency is the number of funcs that can be executed concurrently. Defaults to 1.
+	// Funcs added with the same key are never executed concurrently and are executed in the chan order.
+	Concurrency int
+	// FullPolicy decides what happens when adding a func to a full buffer. Defaults to FullPolicyBlock.
+	FullPolicy string
+	Order      string
 	// By default the funcs not yet processed when the context is cancelled will be dropped. However if TaskFunc is not
 	// nil all funcs will be processed even after the context has been cancelled.
 	TaskFunc astiworker.TaskFunc
 }
 
 // NewChan creates a new Chan
-func NewChan(o ChanOptions) *Chan {
-	return &Chan{
-		c:      sync.NewCond(&sync.Mutex{}),
-		mc:     &sync.Mutex{},
-		mf:     &sync.Mutex{},
-		o:      o,
-		oStart: &sync.Once{},
-		oStop:  &sync.Once{},
+func NewChan(o ChanOptions) (c *Chan) {
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.FullPolicy == "" {
+		o.FullPolicy = FullPolicyBlock
+	}
+	c = &Chan{
+		c:           sync.NewCond(&sync.Mutex{}),
+		delayed:     newDelayedChanHeap(),
+		keys:        make(map[string]*chanKey),
+		mc:          &sync.Mutex{},
+		mf:          &sync.Mutex{},
+		o:           o,
+		oStart:      &sync.Once{},
+		oStop:       &sync.Once{},
+		ready:       newReadyChanHeap(o.Order),
+		statDropped: astistat.NewIncrementStat(),
+		statWork:    astistat.NewDurationRatioStat(),
 	}
+	c.cSpace = sync.NewCond(c.mf)
+	return
 }
 
 // Start starts the chan by looping through functions in the buffer and executing them if any, or waiting for a new one
-// otherwise
+// otherwise. It blocks until all executors are done.
 func (c *Chan) Start(ctx context.Context) {
 	// Make sure to start only once
 	c.oStart.Do(func() {
@@ -57,6 +115,11 @@ func (c *Chan) Start(ctx context.Context) {
 		c.ctx, c.cancel = context.WithCancel(ctx)
 		c.mc.Unlock()
 
+		// Reset stopped
+		c.mf.Lock()
+		c.stopped = false
+		c.mf.Unlock()
+
 		// Reset once
 		c.oStop = &sync.Once{}
 
@@ -67,53 +130,189 @@ func (c *Chan) Start(ctx context.Context) {
 
 			// Signal
 			c.c.L.Lock()
-			c.c.Signal()
+			c.c.Broadcast()
 			c.c.L.Unlock()
-		}()
-
-		// Loop
-		for {
-			// Lock cond here in case a func is added between retrieving l and doing the if on it
-			c.c.L.Lock()
 
-			// Get number of funcs in buffer
+			// Unblock funcs waiting for room in the buffer
 			c.mf.Lock()
-			l := len(c.fs)
+			c.cSpace.Broadcast()
 			c.mf.Unlock()
+		}()
 
-			// Only return if context has been cancelled and:
-			//   - the user wants to drop funcs that has not yet been processed
-			//   - the buffer is empty otherwise
-			c.mc.Lock()
-			if c.ctx.Err() != nil && (c.o.TaskFunc == nil || l == 0) {
-				c.mc.Unlock()
-				c.c.L.Unlock()
-				return
-			}
-			c.mc.Unlock()
+		// Start executors
+		wg := &sync.WaitGroup{}
+		for idx := 0; idx < c.o.Concurrency; idx++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.execute()
+			}()
+		}
+		wg.Wait()
+	})
+}
 
-			// No funcs in buffer
-			if l == 0 {
-				c.c.Wait()
-				c.c.L.Unlock()
-				continue
-			}
+// execute loops through functions in the buffer and executes them if any, or waits for a new one otherwise
+func (c *Chan) execute() {
+	for {
+		// Lock cond here in case a func is added between retrieving l and doing the if on it
+		c.c.L.Lock()
+
+		// Get number of funcs in buffer
+		// Once the context has been cancelled, delayed funcs don't wait anymore
+		c.mc.Lock()
+		ctxDone := c.ctx.Err() != nil
+		c.mc.Unlock()
+		c.mf.Lock()
+		c.promote(ctxDone)
+		l := c.len()
+
+		// Only return if context has been cancelled and:
+		//   - the user wants to drop funcs that has not yet been processed
+		//   - the buffer is empty otherwise
+		if ctxDone && (c.o.TaskFunc == nil || l == 0) {
+			c.mf.Unlock()
 			c.c.L.Unlock()
+			return
+		}
 
-			// Get first func
-			c.mf.Lock()
-			fn := c.fs[0]
+		// Pop first func that can be executed
+		i := c.pop()
+
+		// No funcs can be executed
+		if i == nil {
+			// Make sure to wake up when the next delayed func is due
+			var t *time.Timer
+			if n := c.delayed.peek(); n != nil {
+				t = time.AfterFunc(time.Until(n.notBefore), func() {
+					c.c.L.Lock()
+					c.c.Signal()
+					c.c.L.Unlock()
+				})
+			}
 			c.mf.Unlock()
 
-			// Execute func
-			fn()
+			// Wait
+			c.c.Wait()
+			c.c.L.Unlock()
+			if t != nil {
+				t.Stop()
+			}
+			continue
+		}
+		c.cSpace.Signal()
+		c.mf.Unlock()
+		c.c.L.Unlock()
 
-			// Remove first func
+		// Execute func
+		c.statWork.Add(i)
+		i.fn()
+		c.statWork.Done(i)
+		i.done()
+
+		// Release key
+		if i.key != "" {
+			// Update keys
 			c.mf.Lock()
-			c.fs = c.fs[1:]
+			ready := c.release(i.key)
 			c.mf.Unlock()
+
+			// The next func with the same key may now be executed
+			if ready {
+				c.c.L.Lock()
+				c.c.Signal()
+				c.c.L.Unlock()
+			}
 		}
-	})
+	}
+}
+
+// pop pops the first ready func
+// Funcs whose key is being executed are not in the ready heap
+// Assumes the lock is held
+func (c *Chan) pop() (i *chanItem) {
+	// No ready funcs
+	if c.ready.Len() == 0 {
+		return
+	}
+
+	// Pop
+	i = heap.Pop(c.ready).(*chanItem)
+
+	// Lock key
+	if i.key != "" {
+		k := c.keys[i.key]
+		k.busy = true
+		k.head = nil
+	}
+	return
+}
+
+// push adds a func that is ready to be executed
+// Assumes the lock is held
+func (c *Chan) push(i *chanItem) {
+	// No key
+	if i.key == "" {
+		heap.Push(c.ready, i)
+		return
+	}
+
+	// Get key
+	k, ok := c.keys[i.key]
+	if !ok {
+		k = &chanKey{pending: &chanHeap{less: c.ready.less}}
+		c.keys[i.key] = k
+	}
+
+	// Key is being executed or its head comes first
+	if k.busy || (k.head != nil && !c.ready.less(i, k.head)) {
+		heap.Push(k.pending, i)
+		c.numPending++
+		return
+	}
+
+	// Replace head
+	if k.head != nil {
+		heap.Remove(c.ready, k.head.idx)
+		heap.Push(k.pending, k.head)
+		c.numPending++
+	}
+	k.head = i
+	heap.Push(c.ready, i)
+}
+
+// next moves the next pending func of a key that is not being executed to the ready heap and returns whether it did
+// Keys with no funcs left are removed
+// Assumes the lock is held
+func (c *Chan) next(key string) bool {
+	k := c.keys[key]
+	if k.busy || k.head != nil {
+		return false
+	}
+	if k.pending.Len() == 0 {
+		delete(c.keys, key)
+		return false
+	}
+	k.head = heap.Pop(k.pending).(*chanItem)
+	c.numPending--
+	heap.Push(c.ready, k.head)
+	return true
+}
+
+// release releases a key once its func has been executed and returns whether a func of the key is now ready
+// Assumes the lock is held
+func (c *Chan) release(key string) bool {
+	c.keys[key].busy = false
+	return c.next(key)
+}
+
+// promote moves delayed funcs that are due to the ready heap
+// Assumes the lock is held
+func (c *Chan) promote(all bool) {
+	n := time.Now()
+	for i := c.delayed.peek(); i != nil && (all || !i.notBefore.After(n)); i = c.delayed.peek() {
+		c.push(heap.Pop(c.delayed).(*chanItem))
+	}
 }
 
 // Stop stops the chan
@@ -125,43 +324,85 @@ func (c *Chan) Stop() {
 			c.cancel()
 		}
 
+		// Unblock funcs waiting for room in the buffer, even if the chan was never started
+		c.mf.Lock()
+		c.stopped = true
+		c.cSpace.Broadcast()
+		c.mf.Unlock()
+
 		// Reset once
 		c.oStart = &sync.Once{}
 	})
 }
 
+// ChanAddOptions represents options when adding a func to the chan
+type ChanAddOptions struct {
+	// Funcs with the same non-empty key are never executed concurrently
+	Key string
+	// NotBefore is the time before which the func won't be executed
+	NotBefore time.Time
+	// Funcs with a higher priority are executed first. Defaults to 0.
+	Priority int
+}
+
 // Add adds a new item to the chan
-func (c *Chan) Add(fn func()) {
+// If the buffer is full, the behavior depends on the full policy. Funcs added once the chan is stopped are dropped.
+func (c *Chan) Add(fn func()) error {
+	return c.AddWithOptions(fn, ChanAddOptions{})
+}
+
+// AddWithOptions adds a new item to the chan with a specific priority and/or "not before" timestamp
+func (c *Chan) AddWithOptions(fn func(), o ChanAddOptions) error {
 	// Check context
-	c.mc.Lock()
-	if c.ctx != nil && c.ctx.Err() != nil {
-		c.mc.Unlock()
-		return
+	if c.isStopped() {
+		return nil
 	}
-	c.mc.Unlock()
-
-	// Wrap func
-	wfn := fn
-	if c.o.TaskFunc != nil {
-		// Create task
-		t := c.o.TaskFunc()
 
-		// Wrap function
-		wfn = func() {
-			// Task is done
-			defer t.Done()
+	// Lock
+	c.mf.Lock()
 
-			// Custom
-			fn()
+	// Buffer is full
+	if c.o.Capacity > 0 && c.len() >= c.o.Capacity {
+		switch c.o.FullPolicy {
+		case FullPolicyDropNewest:
+			c.mf.Unlock()
+			c.statDropped.Add(1)
+			return nil
+		case FullPolicyDropOldest:
+			c.dropOldest()
+			c.statDropped.Add(1)
+		case FullPolicyError:
+			c.mf.Unlock()
+			return ErrChanFull
+		default:
+			for c.len() >= c.o.Capacity {
+				if c.stopped || c.isStopped() {
+					c.mf.Unlock()
+					return nil
+				}
+				c.cSpace.Wait()
+			}
 		}
 	}
 
-	// Add func to buffer
-	c.mf.Lock()
-	if c.o.Order == FILOOrder {
-		c.fs = append([]func(){wfn}, c.fs...)
+	// Create item
+	c.seq++
+	i := &chanItem{
+		fn:        fn,
+		key:       o.Key,
+		notBefore: o.NotBefore,
+		priority:  o.Priority,
+		seq:       c.seq,
+	}
+	if c.o.TaskFunc != nil {
+		i.t = c.o.TaskFunc()
+	}
+
+	// Add item to buffer
+	if i.notBefore.After(time.Now()) {
+		heap.Push(c.delayed, i)
 	} else {
-		c.fs = append(c.fs, wfn)
+		c.push(i)
 	}
 	c.mf.Unlock()
 
@@ -169,11 +410,117 @@ func (c *Chan) Add(fn func()) {
 	c.c.L.Lock()
 	c.c.Signal()
 	c.c.L.Unlock()
+	return nil
+}
+
+// Assumes the lock is held
+func (c *Chan) len() int {
+	return c.ready.Len() + c.delayed.Len() + c.numPending
+}
+
+// dropOldest drops the func that has been added first
+// Assumes the lock is held
+func (c *Chan) dropOldest() {
+	// Get oldest func
+	var h *chanHeap
+	var o *chanItem
+	for _, v := range append([]*chanHeap{c.ready, c.delayed}, c.pendingHeaps()...) {
+		if idx := v.oldest(); idx >= 0 && (o == nil || v.is[idx].seq < o.seq) {
+			h, o = v, v.is[idx]
+		}
+	}
+	if o == nil {
+		return
+	}
+
+	// Drop func
+	heap.Remove(h, o.idx)
+	o.done()
+	if h != c.ready && h != c.delayed {
+		c.numPending--
+	}
+
+	// Update key
+	if o.key != "" && h != c.delayed {
+		if k := c.keys[o.key]; k.head == o {
+			k.head = nil
+		}
+		c.next(o.key)
+	}
+}
+
+// Assumes the lock is held
+func (c *Chan) pendingHeaps() (hs []*chanHeap) {
+	for _, k := range c.keys {
+		hs = append(hs, k.pending)
+	}
+	return
+}
+
+func (c *Chan) isStopped() bool {
+	c.mc.Lock()
+	defer c.mc.Unlock()
+	return c.ctx != nil && c.ctx.Err() != nil
+}
+
+// Len returns the number of funcs waiting in the buffer
+func (c *Chan) Len() int {
+	c.mf.Lock()
+	defer c.mf.Unlock()
+	return c.len()
 }
 
 // Reset resets the chan
 func (c *Chan) Reset() {
 	c.mf.Lock()
 	defer c.mf.Unlock()
-	c.fs = []func(){}
+	c.ready.reset()
+	c.delayed.reset()
+	for n, k := range c.keys {
+		k.head = nil
+		k.pending.reset()
+		if !k.busy {
+			delete(c.keys, n)
+		}
+	}
+	c.numPending = 0
+	c.cSpace.Broadcast()
+}
+
+// AddStats adds chan stats
+func (c *Chan) AddStats(s *astistat.Stater) {
+	// Add buffer stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Number of funcs waiting in the buffer",
+		Label:       "Buffer length",
+		Unit:        "funcs",
+	}, astistat.StatHandlerWithoutStart(func(delta time.Duration) interface{} { return c.Len() }))
+
+	// Add dropped stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Number of funcs dropped per second because the buffer was full",
+		Label:       "Dropped",
+		Unit:        "funcs/s",
+	}, c.statDropped)
+
+	// Add work stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Percentage of time spent executing funcs, averaged over executors",
+		Label:       "Work ratio",
+		Unit:        "%",
+	}, chanWorkStat{
+		DurationRatioStat: c.statWork,
+		concurrency:       c.o.Concurrency,
+	})
+}
+
+// chanWorkStat normalizes the time spent executing funcs, which is summed over executors, by the concurrency
+type chanWorkStat struct {
+	*astistat.DurationRatioStat
+	concurrency int
+}
+
+// Value implements the StatHandler interface
+func (s chanWorkStat) Value(delta time.Duration) interface{} {
+	return s.DurationRatioStat.Value(delta).(float64) / float64(s.concurrency)
 }
@@ -0,0 +1,98 @@
+package astisync
+
+import (
+	"time"
+
+	astiworker "github.com/asticode/go-astitools/worker"
+)
+
+type chanItem struct {
+	fn        func()
+	idx       int // Index in the heap holding the item
+	key       string
+	notBefore time.Time
+	priority  int
+	seq       uint64
+	t         *astiworker.Task
+}
+
+func (i *chanItem) done() {
+	if i.t != nil {
+		i.t.Done()
+	}
+}
+
+// chanHeap implements heap.Interface
+type chanHeap struct {
+	is   []*chanItem
+	less func(a, b *chanItem) bool
+}
+
+func newReadyChanHeap(order string) *chanHeap {
+	return &chanHeap{less: func(a, b *chanItem) bool {
+		if a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		if order == FILOOrder {
+			return a.seq > b.seq
+		}
+		return a.seq < b.seq
+	}}
+}
+
+func newDelayedChanHeap() *chanHeap {
+	return &chanHeap{less: func(a, b *chanItem) bool {
+		if !a.notBefore.Equal(b.notBefore) {
+			return a.notBefore.Before(b.notBefore)
+		}
+		return a.seq < b.seq
+	}}
+}
+
+func (h *chanHeap) Len() int           { return len(h.is) }
+func (h *chanHeap) Less(i, j int) bool { return h.less(h.is[i], h.is[j]) }
+
+func (h *chanHeap) Swap(i, j int) {
+	h.is[i], h.is[j] = h.is[j], h.is[i]
+	h.is[i].idx = i
+	h.is[j].idx = j
+}
+
+func (h *chanHeap) Push(x interface{}) {
+	i := x.(*chanItem)
+	i.idx = len(h.is)
+	h.is = append(h.is, i)
+}
+
+func (h *chanHeap) Pop() interface{} {
+	i := h.is[len(h.is)-1]
+	h.is[len(h.is)-1] = nil
+	h.is = h.is[:len(h.is)-1]
+	i.idx = -1
+	return i
+}
+
+func (h *chanHeap) peek() *chanItem {
+	if len(h.is) == 0 {
+		return nil
+	}
+	return h.is[0]
+}
+
+// oldest returns the index of the item with the lowest sequence, or -1 if the heap is empty
+func (h *chanHeap) oldest() (idx int) {
+	idx = -1
+	for k, i := range h.is {
+		if idx < 0 || i.seq < h.is[idx].seq {
+			idx = k
+		}
+	}
+	return
+}
+
+func (h *chanHeap) reset() {
+	for _, i := range h.is {
+		i.done()
+	}
+	h.is = nil
+}
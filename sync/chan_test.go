@@ -0,0 +1,309 @@
+package astisync
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astitools/stat"
+	"github.com/stretchr/testify/assert"
+)
+
+func drainChan(c *Chan) {
+	ctx, cancel := context.WithCancel(context.Background())
+	chanDone := make(chan bool)
+	go func() {
+		c.Start(ctx)
+		close(chanDone)
+	}()
+	for c.Len() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	<-chanDone
+}
+
+func TestChan_FullPolicies(t *testing.T) {
+	// Drop newest
+	var is []int
+	c := NewChan(ChanOptions{Capacity: 2, FullPolicy: FullPolicyDropNewest})
+	for idx := 0; idx < 3; idx++ {
+		i := idx
+		assert.NoError(t, c.Add(func() { is = append(is, i) }))
+	}
+	assert.Equal(t, 2, c.Len())
+	drainChan(c)
+	assert.Equal(t, []int{0, 1}, is)
+
+	// Drop oldest
+	is = []int{}
+	c = NewChan(ChanOptions{Capacity: 2, FullPolicy: FullPolicyDropOldest})
+	for idx := 0; idx < 3; idx++ {
+		i := idx
+		assert.NoError(t, c.Add(func() { is = append(is, i) }))
+	}
+	drainChan(c)
+	assert.Equal(t, []int{1, 2}, is)
+
+	// Error
+	c = NewChan(ChanOptions{Capacity: 1, FullPolicy: FullPolicyError})
+	assert.NoError(t, c.Add(func() {}))
+	assert.Equal(t, ErrChanFull, c.Add(func() {}))
+	c.Reset()
+	assert.Equal(t, 0, c.Len())
+	assert.NoError(t, c.Add(func() {}))
+}
+
+func TestChan_FullPolicyBlock(t *testing.T) {
+	// Init
+	c := NewChan(ChanOptions{Capacity: 1})
+	assert.NoError(t, c.Add(func() {}))
+
+	// Add blocks until there's room in the buffer
+	m := &sync.Mutex{}
+	var added bool
+	chanDone := make(chan bool)
+	go func() {
+		c.Add(func() {})
+		m.Lock()
+		added = true
+		m.Unlock()
+		close(chanDone)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	m.Lock()
+	assert.False(t, added)
+	m.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	select {
+	case <-chanDone:
+	case <-time.After(time.Second):
+		t.Error("add is still blocking")
+	}
+
+	// Blocking add returns when chan is stopped
+	chanBlock := make(chan bool)
+	c.Add(func() { <-chanBlock })
+	time.Sleep(5 * time.Millisecond)
+	c.Add(func() {})
+	chanDone = make(chan bool)
+	go func() {
+		c.Add(func() {})
+		close(chanDone)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	c.Stop()
+	select {
+	case <-chanDone:
+	case <-time.After(time.Second):
+		t.Error("add is still blocking")
+	}
+	close(chanBlock)
+
+	// Blocking add returns when a chan that was never started is stopped
+	c = NewChan(ChanOptions{Capacity: 1})
+	assert.NoError(t, c.Add(func() {}))
+	chanDone = make(chan bool)
+	go func() {
+		c.Add(func() {})
+		close(chanDone)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	c.Stop()
+	select {
+	case <-chanDone:
+	case <-time.After(time.Second):
+		t.Error("add is still blocking")
+	}
+}
+
+func TestChan_FILOOrder(t *testing.T) {
+	// Init
+	var is []int
+	c := NewChan(ChanOptions{Order: FILOOrder})
+	for idx := 0; idx < 3; idx++ {
+		i := idx
+		c.Add(func() { is = append(is, i) })
+	}
+
+	// Drain
+	drainChan(c)
+	assert.Equal(t, []int{2, 1, 0}, is)
+}
+
+func TestChan_AddWithOptions(t *testing.T) {
+	// Init
+	m := &sync.Mutex{}
+	var is []int
+	executedAt := make(map[int]time.Time)
+	chanExecuted := make(chan int, 6)
+	c := NewChan(ChanOptions{})
+	add := func(i int) func() {
+		return func() {
+			m.Lock()
+			is = append(is, i)
+			executedAt[i] = time.Now()
+			m.Unlock()
+			chanExecuted <- i
+		}
+	}
+	wait := func(n int) {
+		for idx := 0; idx < n; idx++ {
+			select {
+			case <-chanExecuted:
+			case <-time.After(time.Second):
+				t.Fatal("func wasn't executed")
+			}
+		}
+	}
+
+	// Priority
+	c.Add(add(0))
+	c.AddWithOptions(add(1), ChanAddOptions{Priority: 1})
+	c.AddWithOptions(add(2), ChanAddOptions{Priority: -1})
+	c.AddWithOptions(add(3), ChanAddOptions{Priority: 1})
+
+	// Not before
+	notBefore4 := time.Now().Add(300 * time.Millisecond)
+	notBefore5 := time.Now().Add(100 * time.Millisecond)
+	c.AddWithOptions(add(4), ChanAddOptions{NotBefore: notBefore4, Priority: 2})
+	c.AddWithOptions(add(5), ChanAddOptions{NotBefore: notBefore5})
+	assert.Equal(t, 6, c.Len())
+
+	// Start
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	wait(4)
+	m.Lock()
+	assert.Equal(t, []int{1, 3, 0, 2}, is)
+	m.Unlock()
+	wait(2)
+	m.Lock()
+	assert.Equal(t, []int{1, 3, 0, 2, 5, 4}, is)
+	assert.False(t, executedAt[4].Before(notBefore4))
+	assert.False(t, executedAt[5].Before(notBefore5))
+	m.Unlock()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestChan_Concurrency(t *testing.T) {
+	// Init
+	m := &sync.Mutex{}
+	var running, max int
+	ks := make(map[string][]int)
+	c := NewChan(ChanOptions{Concurrency: 3})
+	add := func(k string, i int) {
+		c.AddWithOptions(func() {
+			m.Lock()
+			running++
+			if running > max {
+				max = running
+			}
+			ks[k] = append(ks[k], i)
+			m.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			m.Lock()
+			running--
+			m.Unlock()
+		}, ChanAddOptions{Key: k})
+	}
+	for idx := 0; idx < 4; idx++ {
+		add("a", idx)
+		add("b", idx)
+	}
+
+	// Drain
+	drainChan(c)
+	assert.Equal(t, 2, max)
+	assert.Equal(t, map[string][]int{
+		"a": {0, 1, 2, 3},
+		"b": {0, 1, 2, 3},
+	}, ks)
+
+	// Funcs without key
+	max = 0
+	c = NewChan(ChanOptions{Concurrency: 3})
+	for idx := 0; idx < 6; idx++ {
+		add("", idx)
+	}
+	drainChan(c)
+	assert.Equal(t, 3, max)
+}
+
+func TestChan_Keys(t *testing.T) {
+	// Init
+	c := NewChan(ChanOptions{Concurrency: 2})
+	fn := func() {}
+	c.AddWithOptions(fn, ChanAddOptions{Key: "a"})
+	c.AddWithOptions(fn, ChanAddOptions{Key: "a", Priority: 1})
+	c.AddWithOptions(fn, ChanAddOptions{Key: "b"})
+
+	// Only the first func of each key is ready
+	assert.Equal(t, 3, c.Len())
+	assert.Equal(t, 2, c.ready.Len())
+	i := c.pop()
+	assert.Equal(t, "a", i.key)
+	assert.Equal(t, 1, i.priority)
+
+	// Funcs added while their key is being executed are pending
+	c.AddWithOptions(fn, ChanAddOptions{Key: "a", Priority: 2})
+	assert.Equal(t, 1, c.ready.Len())
+	assert.Equal(t, uint64(3), c.pop().seq)
+	assert.Nil(t, c.pop())
+
+	// Releasing the key makes its next func ready
+	assert.True(t, c.release("a"))
+	i = c.pop()
+	assert.Equal(t, uint64(4), i.seq)
+	assert.True(t, c.release("a"))
+	assert.Equal(t, uint64(1), c.pop().seq)
+	assert.False(t, c.release("a"))
+	assert.False(t, c.release("b"))
+	assert.Empty(t, c.keys)
+	assert.Equal(t, 0, c.Len())
+
+	// Dropping the first func of a key makes its next func ready
+	c = NewChan(ChanOptions{Capacity: 2, FullPolicy: FullPolicyDropOldest})
+	c.AddWithOptions(fn, ChanAddOptions{Key: "a"})
+	c.AddWithOptions(fn, ChanAddOptions{Key: "a"})
+	c.AddWithOptions(fn, ChanAddOptions{Key: "b"})
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, 2, c.ready.Len())
+	assert.Equal(t, 0, c.numPending)
+	c.Reset()
+	assert.Equal(t, 0, c.Len())
+	assert.Empty(t, c.keys)
+}
+
+func TestChan_HotKey(t *testing.T) {
+	// Init
+	var n int
+	c := NewChan(ChanOptions{Concurrency: 4})
+	for idx := 0; idx < 20000; idx++ {
+		c.AddWithOptions(func() { n++ }, ChanAddOptions{Key: "a"})
+	}
+
+	// Drain
+	drainChan(c)
+	assert.Equal(t, 20000, n)
+}
+
+func TestChanWorkStat(t *testing.T) {
+	s := chanWorkStat{
+		DurationRatioStat: astistat.NewDurationRatioStat(),
+		concurrency:       2,
+	}
+	s.Start()
+	defer s.Stop()
+	startedAt := time.Now()
+	s.Add(1)
+	s.Add(2)
+	time.Sleep(10 * time.Millisecond)
+	v := s.Value(time.Since(startedAt)).(float64)
+	assert.InDelta(t, 100, v, 20)
+}
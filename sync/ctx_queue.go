@@ -2,12 +2,19 @@ package astisync
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 
 	"github.com/asticode/go-astitools/stat"
 )
 
+// CtxQueue errors
+var (
+	ErrCtxQueueBusy = errors.New("astisync: ctx queue is busy")
+	ErrCtxQueueDone = errors.New("astisync: ctx queue is done")
+)
+
 // CtxQueue is a queue that can
 // - handle a context without dropping any messages sent before the context is cancelled
 // - ensure that sending a message is not blocking if
@@ -15,23 +22,52 @@ import (
 //     - the context has been cancelled
 type CtxQueue struct {
 	c          chan ctxQueueMessage
+	cDone      chan bool
 	ctxIsDone  uint32
 	hasStarted uint32
+	md         *sync.Mutex // Locks cDone
 	o          *sync.Once
 	startC     *sync.Cond
 	statListen *astistat.DurationRatioStat
 }
 
 type ctxQueueMessage struct {
-	c         *sync.Cond
 	ctxIsDone bool
+	f         *CtxQueueFuture
 	p         interface{}
 }
 
+// CtxQueueHandler handles a message and returns a result
+type CtxQueueHandler func(p interface{}) (interface{}, error)
+
+// CtxQueueFuture represents the future result of a message
+type CtxQueueFuture struct {
+	c   chan bool
+	err error
+	v   interface{}
+}
+
+func newCtxQueueFuture() *CtxQueueFuture {
+	return &CtxQueueFuture{c: make(chan bool)}
+}
+
+// Done returns a channel closed once the message has been fully processed
+func (f *CtxQueueFuture) Done() <-chan bool {
+	return f.c
+}
+
+// Wait blocks until the message has been fully processed and returns the handler's result
+func (f *CtxQueueFuture) Wait() (interface{}, error) {
+	<-f.c
+	return f.v, f.err
+}
+
 // NewCtxQueue creates a new ctx queue
 func NewCtxQueue() *CtxQueue {
 	return &CtxQueue{
 		c:          make(chan ctxQueueMessage),
+		cDone:      make(chan bool),
+		md:         &sync.Mutex{},
 		o:          &sync.Once{},
 		startC:     sync.NewCond(&sync.Mutex{}),
 		statListen: astistat.NewDurationRatioStat(),
@@ -49,18 +85,48 @@ func (q *CtxQueue) HandleCtx(ctx context.Context) {
 	q.startC.Broadcast()
 	q.startC.L.Unlock()
 
+	// Unblock senders
+	q.md.Lock()
+	select {
+	case <-q.cDone:
+	default:
+		close(q.cDone)
+	}
+	q.md.Unlock()
+
 	// If the queue has started, send the ctx message
 	if d := atomic.LoadUint32(&q.hasStarted); d == 1 {
 		q.c <- ctxQueueMessage{ctxIsDone: true}
 	}
 }
 
+func (q *CtxQueue) done() <-chan bool {
+	q.md.Lock()
+	defer q.md.Unlock()
+	return q.cDone
+}
+
 // Start starts the queue
 func (q *CtxQueue) Start(fn func(p interface{})) {
+	q.StartWithResult(func(p interface{}) (interface{}, error) {
+		fn(p)
+		return nil, nil
+	})
+}
+
+// StartWithResult starts the queue with a handler whose result is forwarded to the sender
+func (q *CtxQueue) StartWithResult(fn CtxQueueHandler) {
 	// Make sure the queue can only be started once
 	q.o.Do(func() {
 		// Reset ctx
 		atomic.StoreUint32(&q.ctxIsDone, 0)
+		q.md.Lock()
+		select {
+		case <-q.cDone:
+			q.cDone = make(chan bool)
+		default:
+		}
+		q.md.Unlock()
 
 		// Broadcast
 		q.startC.L.Lock()
@@ -84,12 +150,10 @@ func (q *CtxQueue) Start(fn func(p interface{})) {
 				}
 
 				// Handle payload
-				fn(m.p)
+				m.f.v, m.f.err = fn(m.p)
 
-				// Broadcast the fact that the process is done
-				m.c.L.Lock()
-				m.c.Broadcast()
-				m.c.L.Unlock()
+				// Signal the fact that the process is done
+				close(m.f.c)
 
 				// Wait is starting
 				q.statListen.Add(true)
@@ -99,42 +163,111 @@ func (q *CtxQueue) Start(fn func(p interface{})) {
 }
 
 // Send sends a message in the queue and blocks until the message has been fully processed
-// Block indicates whether to block until the message has been fully processed
 func (q *CtxQueue) Send(p interface{}) {
-	// Make sure to lock here
-	q.startC.L.Lock()
+	q.SendCtx(context.Background(), p)
+}
+
+// SendCtx sends a message in the queue and blocks until the message has been fully processed or ctx is done
+// If ctx is done after the message has been received, the message is still processed but its result is lost
+func (q *CtxQueue) SendCtx(ctx context.Context, p interface{}) (v interface{}, err error) {
+	// Send
+	var f *CtxQueueFuture
+	if f, err = q.SendAsync(ctx, p); err != nil {
+		return
+	}
+
+	// Wait for handling to be done
+	select {
+	case <-f.Done():
+		return f.Wait()
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+}
+
+// SendAsync sends a message in the queue and blocks until the message has been received, the queue is done or ctx is
+// done. The returned future can be used to wait for the message to be fully processed.
+func (q *CtxQueue) SendAsync(ctx context.Context, p interface{}) (f *CtxQueueFuture, err error) {
+	// Wait for start
+	if err = q.waitForStart(ctx); err != nil {
+		return
+	}
+
+	// Send message
+	m := ctxQueueMessage{
+		f: newCtxQueueFuture(),
+		p: p,
+	}
+	select {
+	case q.c <- m:
+		f = m.f
+	case <-q.done():
+		err = ErrCtxQueueDone
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
 
+// TrySend sends a message in the queue only if it can be received right away, and returns ErrCtxQueueBusy otherwise
+// The returned future can be used to wait for the message to be fully processed.
+func (q *CtxQueue) TrySend(p interface{}) (f *CtxQueueFuture, err error) {
 	// Context is done
 	if d := atomic.LoadUint32(&q.ctxIsDone); d == 1 {
-		q.startC.L.Unlock()
+		err = ErrCtxQueueDone
 		return
 	}
 
-	// Check whether queue has been started
+	// Send message
+	m := ctxQueueMessage{
+		f: newCtxQueueFuture(),
+		p: p,
+	}
+	select {
+	case q.c <- m:
+		f = m.f
+	default:
+		err = ErrCtxQueueBusy
+	}
+	return
+}
+
+func (q *CtxQueue) waitForStart(ctx context.Context) error {
+	// Make sure to lock here
+	q.startC.L.Lock()
+	defer q.startC.L.Unlock()
+
+	// Make sure to wake up when ctx is done
 	if d := atomic.LoadUint32(&q.hasStarted); d == 0 {
-		// We either wait for the queue to start or for the ctx to be done
-		q.startC.Wait()
+		cDone := make(chan bool)
+		defer close(cDone)
+		go func() {
+			select {
+			case <-ctx.Done():
+				q.startC.L.Lock()
+				q.startC.Broadcast()
+				q.startC.L.Unlock()
+			case <-cDone:
+			}
+		}()
+	}
 
+	// We either wait for the queue to start or for a context to be done
+	for {
 		// Context is done
 		if d := atomic.LoadUint32(&q.ctxIsDone); d == 1 {
-			q.startC.L.Unlock()
-			return
+			return ErrCtxQueueDone
+		} else if ctx.Err() != nil {
+			return ctx.Err()
 		}
-	}
-	q.startC.L.Unlock()
 
-	// Create cond
-	c := sync.NewCond(&sync.Mutex{})
-	c.L.Lock()
-
-	// Send message
-	q.c <- ctxQueueMessage{
-		c: c,
-		p: p,
+		// Queue has started
+		if d := atomic.LoadUint32(&q.hasStarted); d == 1 {
+			return nil
+		}
+		q.startC.Wait()
 	}
-
-	// Wait for handling to be done
-	c.Wait()
 }
 
 // Stop stops the queue properly
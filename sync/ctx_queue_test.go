@@ -0,0 +1,81 @@
+package astisync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCtxQueue(t *testing.T) {
+	// Init
+	q := NewCtxQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.HandleCtx(ctx)
+
+	// Send before start
+	sctx, scancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer scancel()
+	_, err := q.SendCtx(sctx, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, err = q.TrySend(1)
+	assert.Equal(t, ErrCtxQueueBusy, err)
+
+	// Start
+	chanBlock := make(chan bool)
+	go q.StartWithResult(func(p interface{}) (interface{}, error) {
+		switch p {
+		case "block":
+			<-chanBlock
+		case "error":
+			return nil, errors.New("test")
+		}
+		return p, nil
+	})
+
+	// Send
+	v, err := q.SendCtx(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+	_, err = q.SendCtx(context.Background(), "error")
+	assert.EqualError(t, err, "test")
+
+	// Async
+	f, err := q.SendAsync(context.Background(), "block")
+	assert.NoError(t, err)
+	_, err = q.TrySend(3)
+	assert.Equal(t, ErrCtxQueueBusy, err)
+	sctx, scancel = context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer scancel()
+	_, err = q.SendCtx(sctx, 3)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	close(chanBlock)
+	v, err = f.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "block", v)
+
+	// Context is done
+	chanBlock = make(chan bool)
+	f, err = q.SendAsync(context.Background(), "block")
+	assert.NoError(t, err)
+	chanDone := make(chan error)
+	go func() {
+		_, err := q.SendCtx(context.Background(), 4)
+		chanDone <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	select {
+	case err = <-chanDone:
+		assert.Equal(t, ErrCtxQueueDone, err)
+	case <-time.After(time.Second):
+		t.Error("send is still blocking")
+	}
+	close(chanBlock)
+	f.Wait()
+	_, err = q.TrySend(5)
+	assert.Equal(t, ErrCtxQueueDone, err)
+}
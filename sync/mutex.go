@@ -15,15 +15,37 @@ type RWMutex struct {
 	log                      bool
 	mutex                    *sync.RWMutex
 	name                     string
+	p                        *rwMutexProfiler
+}
+
+// RWMutexOptions represents RWMutex options
+type RWMutexOptions struct {
+	Log  bool
+	Name string
+	// Profile enables recording wait and hold times per call site as well as current holders. It adds overhead to
+	// each lock and unlock.
+	Profile bool
 }
 
 // NewRWMutex creates a new RWMutex
 func NewRWMutex(name string, log bool) *RWMutex {
-	return &RWMutex{
-		log:   log,
+	return NewRWMutexWithOptions(RWMutexOptions{
+		Log:  log,
+		Name: name,
+	})
+}
+
+// NewRWMutexWithOptions creates a new RWMutex with options
+func NewRWMutexWithOptions(o RWMutexOptions) (m *RWMutex) {
+	m = &RWMutex{
+		log:   o.Log,
 		mutex: &sync.RWMutex{},
-		name:  name,
+		name:  o.Name,
+	}
+	if o.Profile {
+		m.p = newRWMutexProfiler()
 	}
+	return
 }
 
 // Lock write locks the mutex
@@ -35,7 +57,16 @@ func (m *RWMutex) Lock() {
 	if m.log {
 		astilog.Debugf("Requesting lock for %s at %s", m.name, caller)
 	}
+	var a *rwMutexAcquisition
+	if m.p != nil {
+		a = m.p.waiting(caller, LockKindWrite)
+	}
+	st := lockOrder.requested(m.name)
 	m.mutex.Lock()
+	lockOrder.acquired(m.name, st)
+	if a != nil {
+		m.p.acquired(a)
+	}
 	if m.log {
 		astilog.Debugf("Lock acquired for %s at %s", m.name, caller)
 	}
@@ -44,6 +75,10 @@ func (m *RWMutex) Lock() {
 
 // Unlock write unlocks the mutex
 func (m *RWMutex) Unlock() {
+	if m.p != nil {
+		m.p.released(LockKindWrite)
+	}
+	lockOrder.released(m.name)
 	m.mutex.Unlock()
 	if m.log {
 		astilog.Debugf("Unlock executed for %s", m.name)
@@ -59,7 +94,16 @@ func (m *RWMutex) RLock() {
 	if m.log {
 		astilog.Debugf("Requesting rlock for %s at %s", m.name, caller)
 	}
+	var a *rwMutexAcquisition
+	if m.p != nil {
+		a = m.p.waiting(caller, LockKindRead)
+	}
+	st := lockOrder.requested(m.name)
 	m.mutex.RLock()
+	lockOrder.acquired(m.name, st)
+	if a != nil {
+		m.p.acquired(a)
+	}
 	if m.log {
 		astilog.Debugf("RLock acquired for %s at %s", m.name, caller)
 	}
@@ -68,6 +112,10 @@ func (m *RWMutex) RLock() {
 
 // RUnlock read unlocks the mutex
 func (m *RWMutex) RUnlock() {
+	if m.p != nil {
+		m.p.released(LockKindRead)
+	}
+	lockOrder.released(m.name)
 	m.mutex.RUnlock()
 	if m.log {
 		astilog.Debugf("RUnlock executed for %s", m.name)
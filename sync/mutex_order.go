@@ -0,0 +1,220 @@
+package astisync
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astitools/debug"
+)
+
+// LockOrderEdge represents the acquisition of a mutex while holding another one
+type LockOrderEdge struct {
+	From      string
+	FromStack astidebug.Stack
+	To        string
+	ToStack   astidebug.Stack
+}
+
+// LockOrderInversion represents mutexes acquired in inconsistent orders, which may lead to a deadlock
+// Previous is the path of edges observed earlier leading from Current.To back to Current.From
+type LockOrderInversion struct {
+	Current  LockOrderEdge
+	Previous []LockOrderEdge
+}
+
+// LockOrderOptions represents lock order detection options
+type LockOrderOptions struct {
+	// OnInversion is called the first time an inversion is observed between 2 mutexes. Defaults to logging it.
+	OnInversion func(i LockOrderInversion)
+}
+
+type lockOrderHeld struct {
+	name  string
+	stack astidebug.Stack
+}
+
+type lockOrderDetector struct {
+	edges    map[string]map[string]LockOrderEdge
+	enabled  uint32
+	held     map[uint64][]lockOrderHeld
+	m        *sync.Mutex // Locks edges, held, o and reported
+	o        LockOrderOptions
+	reported map[[2]string]bool
+}
+
+var lockOrder = &lockOrderDetector{m: &sync.Mutex{}}
+
+// EnableLockOrderDetection enables lock order detection across all named RWMutex instances
+// Mutexes sharing the same name are considered as the same lock. Detection adds a significant overhead to each lock
+// and unlock since acquisition stacks are recorded.
+func EnableLockOrderDetection(o LockOrderOptions) {
+	// Default options
+	if o.OnInversion == nil {
+		o.OnInversion = func(i LockOrderInversion) {
+			astilog.Errorf("astisync: potential deadlock: %s acquired while holding %s at %s whereas %s was acquired while holding %s at %s", i.Current.To, i.Current.From, i.Current.ToStack, i.Previous[0].To, i.Previous[0].From, i.Previous[0].ToStack)
+		}
+	}
+
+	// Reset
+	lockOrder.m.Lock()
+	lockOrder.edges = make(map[string]map[string]LockOrderEdge)
+	lockOrder.held = make(map[uint64][]lockOrderHeld)
+	lockOrder.o = o
+	lockOrder.reported = make(map[[2]string]bool)
+	lockOrder.m.Unlock()
+	atomic.StoreUint32(&lockOrder.enabled, 1)
+}
+
+// DisableLockOrderDetection disables lock order detection
+func DisableLockOrderDetection() {
+	atomic.StoreUint32(&lockOrder.enabled, 0)
+}
+
+// requested records edges between the mutexes held by the current goroutine and the requested one, and returns the
+// acquisition stack
+func (d *lockOrderDetector) requested(name string) (s astidebug.Stack) {
+	// Detection is disabled
+	if name == "" || atomic.LoadUint32(&d.enabled) == 0 {
+		return
+	}
+
+	// Get stack
+	s = astidebug.NewStack()
+	gid := goroutineID()
+
+	// Lock
+	d.m.Lock()
+
+	// Loop through held mutexes
+	var is []LockOrderInversion
+	for _, h := range d.held[gid] {
+		// Edge already exists
+		if h.name == name {
+			continue
+		} else if _, ok := d.edges[h.name][name]; ok {
+			continue
+		}
+
+		// Create edge
+		e := LockOrderEdge{
+			From:      h.name,
+			FromStack: h.stack,
+			To:        name,
+			ToStack:   s,
+		}
+
+		// Check whether held mutex can be reached from the requested one
+		k := [2]string{h.name, name}
+		if h.name > name {
+			k = [2]string{name, h.name}
+		}
+		if p := d.path(name, h.name); len(p) > 0 && !d.reported[k] {
+			d.reported[k] = true
+			is = append(is, LockOrderInversion{
+				Current:  e,
+				Previous: p,
+			})
+		}
+
+		// Add edge
+		if _, ok := d.edges[h.name]; !ok {
+			d.edges[h.name] = make(map[string]LockOrderEdge)
+		}
+		d.edges[h.name][name] = e
+	}
+	fn := d.o.OnInversion
+
+	// Unlock
+	d.m.Unlock()
+
+	// Report inversions
+	for _, i := range is {
+		fn(i)
+	}
+	return
+}
+
+// path returns the edges leading from one mutex to another, if any
+// Assumes the lock is held
+func (d *lockOrderDetector) path(from, to string) (p []LockOrderEdge) {
+	// Breadth first search
+	parents := map[string]LockOrderEdge{}
+	visited := map[string]bool{from: true}
+	q := []string{from}
+	for len(q) > 0 {
+		n := q[0]
+		q = q[1:]
+		for c, e := range d.edges[n] {
+			if visited[c] {
+				continue
+			}
+			visited[c] = true
+			parents[c] = e
+
+			// Target has been reached
+			if c == to {
+				for c != from {
+					p = append([]LockOrderEdge{parents[c]}, p...)
+					c = parents[c].From
+				}
+				return
+			}
+			q = append(q, c)
+		}
+	}
+	return
+}
+
+func (d *lockOrderDetector) acquired(name string, s astidebug.Stack) {
+	// Detection is disabled
+	if s == nil || atomic.LoadUint32(&d.enabled) == 0 {
+		return
+	}
+
+	// Add held mutex
+	gid := goroutineID()
+	d.m.Lock()
+	d.held[gid] = append(d.held[gid], lockOrderHeld{
+		name:  name,
+		stack: s,
+	})
+	d.m.Unlock()
+}
+
+func (d *lockOrderDetector) released(name string) {
+	// Detection is disabled
+	if name == "" || atomic.LoadUint32(&d.enabled) == 0 {
+		return
+	}
+
+	// Lock
+	gid := goroutineID()
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	// Mutexes may be released by a goroutine other than the one that acquired them
+	if !d.remove(gid, name) {
+		for k := range d.held {
+			if d.remove(k, name) {
+				return
+			}
+		}
+	}
+}
+
+// Assumes the lock is held
+func (d *lockOrderDetector) remove(gid uint64, name string) bool {
+	hs := d.held[gid]
+	for idx := len(hs) - 1; idx >= 0; idx-- {
+		if hs[idx].name == name {
+			if hs = append(hs[:idx], hs[idx+1:]...); len(hs) == 0 {
+				delete(d.held, gid)
+			} else {
+				d.held[gid] = hs
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package astisync_test
+
+import (
+	"testing"
+
+	"github.com/asticode/go-astitools/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockOrderDetection(t *testing.T) {
+	// Init
+	var is []astisync.LockOrderInversion
+	astisync.EnableLockOrderDetection(astisync.LockOrderOptions{OnInversion: func(i astisync.LockOrderInversion) {
+		is = append(is, i)
+	}})
+	defer astisync.DisableLockOrderDetection()
+	a := astisync.NewRWMutex("a", false)
+	b := astisync.NewRWMutex("b", false)
+	c := astisync.NewRWMutex("c", false)
+
+	// Consistent order
+	a.Lock()
+	b.RLock()
+	b.RUnlock()
+	a.Unlock()
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	assert.Empty(t, is)
+
+	// Inversion
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+	assert.Len(t, is, 1)
+	assert.Equal(t, "b", is[0].Current.From)
+	assert.Equal(t, "a", is[0].Current.To)
+	assert.NotEmpty(t, is[0].Current.ToStack)
+	assert.Len(t, is[0].Previous, 1)
+	assert.Equal(t, "a", is[0].Previous[0].From)
+	assert.Equal(t, "b", is[0].Previous[0].To)
+	assert.NotEmpty(t, is[0].Previous[0].FromStack)
+
+	// Inversions are only reported once
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+	assert.Len(t, is, 1)
+
+	// Longer cycle
+	b.Lock()
+	c.Lock()
+	c.Unlock()
+	b.Unlock()
+	c.Lock()
+	a.Lock()
+	a.Unlock()
+	c.Unlock()
+	assert.Len(t, is, 2)
+	assert.Len(t, is[1].Previous, 2)
+}
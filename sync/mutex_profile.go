@@ -0,0 +1,290 @@
+package astisync
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astitools/stat"
+	"github.com/pkg/errors"
+)
+
+// Lock kinds
+const (
+	LockKindRead  = "read"
+	LockKindWrite = "write"
+)
+
+// RWMutexSite represents contention stats of a call site
+type RWMutexSite struct {
+	Caller    string        `json:"caller"`
+	Count     int           `json:"count"`
+	HoldMax   time.Duration `json:"hold_max"`
+	HoldTotal time.Duration `json:"hold_total"`
+	Kind      string        `json:"kind"`
+	WaitMax   time.Duration `json:"wait_max"`
+	WaitTotal time.Duration `json:"wait_total"`
+}
+
+// RWMutexHolder represents a current holder of the mutex
+type RWMutexHolder struct {
+	AcquiredAt  time.Time `json:"acquired_at"`
+	Caller      string    `json:"caller"`
+	GoroutineID uint64    `json:"goroutine_id"`
+	Kind        string    `json:"kind"`
+}
+
+// RWMutexProfile represents the contention profile of a mutex
+type RWMutexProfile struct {
+	Holders []RWMutexHolder `json:"holders"`
+	Name    string          `json:"name"`
+	Sites   []RWMutexSite   `json:"sites"`
+}
+
+type rwMutexAcquisition struct {
+	acquiredAt  time.Time
+	caller      string
+	gid         uint64
+	kind        string
+	requestedAt time.Time
+}
+
+type rwMutexProfiler struct {
+	m         *sync.Mutex // Locks readers, sites and writer
+	readers   map[uint64][]*rwMutexAcquisition
+	sites     map[string]*RWMutexSite
+	statHold  *astistat.DurationRatioStat
+	statLocks *astistat.IncrementStat
+	statWait  *astistat.DurationRatioStat
+	writer    *rwMutexAcquisition
+}
+
+func newRWMutexProfiler() *rwMutexProfiler {
+	return &rwMutexProfiler{
+		m:         &sync.Mutex{},
+		readers:   make(map[uint64][]*rwMutexAcquisition),
+		sites:     make(map[string]*RWMutexSite),
+		statHold:  astistat.NewDurationRatioStat(),
+		statLocks: astistat.NewIncrementStat(),
+		statWait:  astistat.NewDurationRatioStat(),
+	}
+}
+
+// goroutineID returns the id of the current goroutine
+func goroutineID() (id uint64) {
+	b := make([]byte, 64)
+	b = bytes.TrimPrefix(b[:runtime.Stack(b, false)], []byte("goroutine "))
+	if idx := bytes.IndexByte(b, ' '); idx > -1 {
+		id, _ = strconv.ParseUint(string(b[:idx]), 10, 64)
+	}
+	return
+}
+
+func (p *rwMutexProfiler) waiting(caller, kind string) (a *rwMutexAcquisition) {
+	a = &rwMutexAcquisition{
+		caller:      caller,
+		gid:         goroutineID(),
+		kind:        kind,
+		requestedAt: time.Now(),
+	}
+	p.statWait.Add(a)
+	return
+}
+
+func (p *rwMutexProfiler) acquired(a *rwMutexAcquisition) {
+	// Update stats
+	a.acquiredAt = time.Now()
+	p.statWait.Done(a)
+	p.statHold.Add(a)
+	p.statLocks.Add(1)
+
+	// Lock
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	// Update site
+	s := p.site(a)
+	s.Count++
+	w := a.acquiredAt.Sub(a.requestedAt)
+	s.WaitTotal += w
+	if w > s.WaitMax {
+		s.WaitMax = w
+	}
+
+	// Update holders
+	if a.kind == LockKindWrite {
+		p.writer = a
+	} else {
+		p.readers[a.gid] = append(p.readers[a.gid], a)
+	}
+}
+
+func (p *rwMutexProfiler) released(kind string) {
+	// Lock
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	// Get acquisition
+	var a *rwMutexAcquisition
+	if kind == LockKindWrite {
+		a, p.writer = p.writer, nil
+	} else {
+		// Read locks may be released by a goroutine other than the one that acquired them, in which case the oldest
+		// read lock is released
+		gid := goroutineID()
+		if _, ok := p.readers[gid]; !ok {
+			var oldest *rwMutexAcquisition
+			for k, as := range p.readers {
+				if oldest == nil || as[0].acquiredAt.Before(oldest.acquiredAt) {
+					oldest, gid = as[0], k
+				}
+			}
+		}
+		if as := p.readers[gid]; len(as) > 0 {
+			a = as[len(as)-1]
+			if len(as) == 1 {
+				delete(p.readers, gid)
+			} else {
+				p.readers[gid] = as[:len(as)-1]
+			}
+		}
+	}
+	if a == nil {
+		return
+	}
+
+	// Update stats
+	p.statHold.Done(a)
+
+	// Update site
+	s := p.site(a)
+	h := time.Since(a.acquiredAt)
+	s.HoldTotal += h
+	if h > s.HoldMax {
+		s.HoldMax = h
+	}
+}
+
+// Assumes the lock is held
+func (p *rwMutexProfiler) site(a *rwMutexAcquisition) (s *RWMutexSite) {
+	k := a.kind + " " + a.caller
+	var ok bool
+	if s, ok = p.sites[k]; !ok {
+		s = &RWMutexSite{
+			Caller: a.caller,
+			Kind:   a.kind,
+		}
+		p.sites[k] = s
+	}
+	return
+}
+
+func (p *rwMutexProfiler) profile(name string) (o RWMutexProfile) {
+	// Lock
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	// Add holders
+	o.Name = name
+	if p.writer != nil {
+		o.Holders = append(o.Holders, newRWMutexHolder(p.writer))
+	}
+	for _, as := range p.readers {
+		for _, a := range as {
+			o.Holders = append(o.Holders, newRWMutexHolder(a))
+		}
+	}
+	sort.Slice(o.Holders, func(i, j int) bool { return o.Holders[i].AcquiredAt.Before(o.Holders[j].AcquiredAt) })
+
+	// Add sites
+	for _, s := range p.sites {
+		o.Sites = append(o.Sites, *s)
+	}
+	sort.Slice(o.Sites, func(i, j int) bool {
+		if o.Sites[i].WaitTotal != o.Sites[j].WaitTotal {
+			return o.Sites[i].WaitTotal > o.Sites[j].WaitTotal
+		}
+		return o.Sites[i].Kind+o.Sites[i].Caller < o.Sites[j].Kind+o.Sites[j].Caller
+	})
+	return
+}
+
+func newRWMutexHolder(a *rwMutexAcquisition) RWMutexHolder {
+	return RWMutexHolder{
+		AcquiredAt:  a.acquiredAt,
+		Caller:      a.caller,
+		GoroutineID: a.gid,
+		Kind:        a.kind,
+	}
+}
+
+// Profile returns the contention profile of the mutex, with sites sorted by total wait time
+// It is empty if profiling is disabled
+func (m *RWMutex) Profile() RWMutexProfile {
+	if m.p == nil {
+		return RWMutexProfile{Name: m.name}
+	}
+	return m.p.profile(m.name)
+}
+
+// TopContended returns the n sites with the highest total wait time
+func (m *RWMutex) TopContended(n int) []RWMutexSite {
+	ss := m.Profile().Sites
+	if len(ss) > n {
+		ss = ss[:n]
+	}
+	return ss
+}
+
+// AddStats adds mutex stats
+// Profiling must be enabled
+func (m *RWMutex) AddStats(s *astistat.Stater) {
+	// Profiling is disabled
+	if m.p == nil {
+		return
+	}
+
+	// Add wait stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Percentage of time spent waiting for " + m.name + ", summed over goroutines",
+		Label:       "Wait ratio",
+		Unit:        "%",
+	}, m.p.statWait)
+
+	// Add hold stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Percentage of time " + m.name + " is held, summed over goroutines",
+		Label:       "Hold ratio",
+		Unit:        "%",
+	}, m.p.statHold)
+
+	// Add locks stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Number of times " + m.name + " is acquired per second",
+		Label:       "Locks",
+		Unit:        "locks/s",
+	}, m.p.statLocks)
+}
+
+// RWMutexProfileHandler returns an http.Handler dumping the contention profiles of mutexes as JSON
+func RWMutexProfileHandler(ms ...*RWMutex) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// Get profiles
+		var ps []RWMutexProfile
+		for _, m := range ms {
+			ps = append(ps, m.Profile())
+		}
+
+		// Write
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(ps); err != nil {
+			astilog.Error(errors.Wrap(err, "astisync: writing mutex profiles failed"))
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package astisync_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astitools/sync"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRWMutex_Profile(t *testing.T) {
+	// Profiling disabled
+	m := astisync.NewRWMutex("test", false)
+	m.Lock()
+	m.Unlock()
+	assert.Empty(t, m.Profile().Sites)
+
+	// Holders
+	m = astisync.NewRWMutexWithOptions(astisync.RWMutexOptions{
+		Name:    "test",
+		Profile: true,
+	})
+	m.RLock()
+	m.RLock()
+	p := m.Profile()
+	assert.Len(t, p.Holders, 2)
+	assert.Equal(t, astisync.LockKindRead, p.Holders[0].Kind)
+	m.RUnlock()
+	m.RUnlock()
+	assert.Empty(t, m.Profile().Holders)
+
+	// Contention
+	m.Lock()
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.Lock()
+		m.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p = m.Profile()
+	assert.Len(t, p.Holders, 1)
+	assert.Equal(t, astisync.LockKindWrite, p.Holders[0].Kind)
+	m.Unlock()
+	wg.Wait()
+	ss := m.TopContended(1)
+	assert.Len(t, ss, 1)
+	assert.Contains(t, ss[0].Caller, "mutex_profile_test.go:41")
+	assert.Equal(t, astisync.LockKindWrite, ss[0].Kind)
+	assert.Equal(t, 1, ss[0].Count)
+	assert.True(t, ss[0].WaitTotal >= 10*time.Millisecond)
+	assert.Len(t, m.Profile().Sites, 4)
+
+	// HTTP
+	rw := httptest.NewRecorder()
+	astisync.RWMutexProfileHandler(m).ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	var ps []astisync.RWMutexProfile
+	assert.NoError(t, json.NewDecoder(rw.Body).Decode(&ps))
+	assert.Len(t, ps, 1)
+	assert.Equal(t, "test", ps[0].Name)
+	assert.Len(t, ps[0].Sites, 4)
+}
@@ -1,9 +1,10 @@
 package astiworker
 
 import (
-	"math"
+	"context"
 
 	"github.com/asticode/go-astiamqp"
+	"github.com/asticode/go-astilog"
 	"github.com/pkg/errors"
 )
 
@@ -15,24 +16,120 @@ type ConfigurationConsumer struct {
 
 // Consume consumes AMQP events
 func (w *Worker) Consume(a *astiamqp.AMQP, cs ...ConfigurationConsumer) (err error) {
+	return w.ConsumeWithOptions(a, TaskOptions{}, cs...)
+}
+
+// ConsumeWithOptions consumes AMQP events in tasks created with options
+// The task name defaults to "amqp"
+func (w *Worker) ConsumeWithOptions(a *astiamqp.AMQP, o TaskOptions, cs ...ConfigurationConsumer) (err error) {
+	// Default name
+	if o.Name == "" {
+		o.Name = "amqp"
+	}
+
 	// Loop through configurations
 	for idxConf, c := range cs {
-		// Loop through workers
-		for idxWorker := 0; idxWorker < int(math.Max(1, float64(c.WorkerCount))); idxWorker++ {
-			if err = a.AddConsumer(c.AMQP); err != nil {
-				err = errors.Wrapf(err, "main: adding consumer #%d for conf #%d %+v failed", idxWorker+1, idxConf+1, c)
-				return
-			}
+		// Create consumer
+		var ac *AMQPConsumer
+		if ac, err = NewAMQPConsumer(a, c.AMQP); err != nil {
+			err = errors.Wrapf(err, "astiworker: creating consumer for conf #%d %+v failed", idxConf+1, c)
+			return
 		}
+
+		// Consume
+		h := c.AMQP.Handler
+		w.ConsumeFrom(ac, ConsumeOptions{
+			ComponentName: o.Name,
+			Handler: func(m Message) error {
+				return h(m.Body, m.RoutingKey, m.Raw.(astiamqp.Acknowledger))
+			},
+			ManualAck:   true,
+			Task:        o,
+			WorkerCount: c.WorkerCount,
+		})
 	}
+	return
+}
 
-	// Execute in a task
-	w.NewTask().Do(func() {
-		// Wait for context to be done
-		<-w.Context().Done()
+// AMQPConsumer represents an AMQP consumer
+// Raw contains the astiamqp.Acknowledger of the message
+type AMQPConsumer struct {
+	a     *astiamqp.AMQP
+	cDone chan bool
+	cMsgs chan Message
+}
+
+// NewAMQPConsumer creates a new AMQP consumer
+// The configuration's handler is ignored
+func NewAMQPConsumer(a *astiamqp.AMQP, c astiamqp.ConfigurationConsumer) (ac *AMQPConsumer, err error) {
+	// Create consumer
+	ac = &AMQPConsumer{
+		a:     a,
+		cDone: make(chan bool),
+		cMsgs: make(chan Message),
+	}
 
-		// Stop amqp
-		a.Stop()
-	})
+	// Add consumer
+	c.Handler = ac.handle
+	if err = a.AddConsumer(c); err != nil {
+		err = errors.Wrap(err, "astiworker: adding amqp consumer failed")
+		return
+	}
 	return
 }
+
+func (c *AMQPConsumer) handle(msg []byte, routingKey string, a astiamqp.Acknowledger) error {
+	// Create message
+	m := Message{
+		Acknowledger: amqpAcknowledger{a: a},
+		Body:         msg,
+		Raw:          a,
+		RoutingKey:   routingKey,
+	}
+
+	// Hand message over
+	select {
+	case c.cMsgs <- m:
+	case <-c.cDone:
+		// Consumer is stopped, requeue message
+		if err := a.Nack(false, true); err != nil {
+			astilog.Error(errors.Wrap(err, "astiworker: nacking amqp message failed"))
+		}
+	}
+	return nil
+}
+
+// Start implements the Consumer interface
+func (c *AMQPConsumer) Start(ctx context.Context, ch chan<- Message) error {
+	for {
+		select {
+		case m := <-c.cMsgs:
+			select {
+			case ch <- m:
+			case <-ctx.Done():
+				if err := m.Acknowledger.Nack(true); err != nil {
+					astilog.Error(errors.Wrap(err, "astiworker: nacking amqp message failed"))
+				}
+			}
+		case <-ctx.Done():
+			// Stop amqp
+			close(c.cDone)
+			c.a.Stop()
+			return nil
+		}
+	}
+}
+
+type amqpAcknowledger struct {
+	a astiamqp.Acknowledger
+}
+
+// Ack implements the Acknowledger interface
+func (a amqpAcknowledger) Ack() error {
+	return a.a.Ack(false)
+}
+
+// Nack implements the Acknowledger interface
+func (a amqpAcknowledger) Nack(requeue bool) error {
+	return a.a.Nack(false, requeue)
+}
@@ -0,0 +1,145 @@
+package astiworker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// Message represents a consumed message
+type Message struct {
+	Acknowledger Acknowledger
+	Body         []byte
+	// Raw contains the transport specific message if any
+	Raw        interface{}
+	RoutingKey string
+}
+
+// Acknowledger represents an object capable of acknowledging a message
+type Acknowledger interface {
+	Ack() error
+	Nack(requeue bool) error
+}
+
+type noopAcknowledger struct{}
+
+// Ack implements the Acknowledger interface
+func (a noopAcknowledger) Ack() error { return nil }
+
+// Nack implements the Acknowledger interface
+func (a noopAcknowledger) Nack(requeue bool) error { return nil }
+
+// MessageHandler represents a func that can handle a message
+type MessageHandler func(m Message) error
+
+// Consumer represents an object capable of consuming messages from a transport
+type Consumer interface {
+	// Start sends messages to ch until ctx is done or there are no messages left to consume. It must not send
+	// messages to ch once it has returned.
+	Start(ctx context.Context, ch chan<- Message) error
+}
+
+// ConsumeOptions represents consume options
+type ConsumeOptions struct {
+	// ComponentName is the name under which the consumer is registered. Defaults to "consumer".
+	ComponentName string
+	Handler       MessageHandler
+	// By default messages are acked when the handler returns no error and nacked otherwise. If ManualAck is true, the
+	// handler is responsible for acking messages.
+	ManualAck bool
+	// If RequeueOnError is true, messages are requeued when nacked automatically
+	RequeueOnError bool
+	// Task name defaults to the component name
+	Task        TaskOptions
+	WorkerCount int
+}
+
+// ConsumeFrom consumes messages from a consumer
+// Messages are handled by WorkerCount goroutines. When the task's context is done, the consumer is stopped and
+// messages being handled are waited for.
+func (w *Worker) ConsumeFrom(c Consumer, o ConsumeOptions) {
+	// Register component
+	s := newStatusComponent(StatusRunning)
+	if o.ComponentName == "" {
+		o.ComponentName = "consumer"
+	}
+	cn := w.RegisterComponent(ComponentTypeConsumer, o.ComponentName, s)
+
+	// Execute in a task
+	if o.Task.Name == "" {
+		o.Task.Name = o.ComponentName
+	}
+	t := w.NewTaskWithOptions(o.Task)
+	t.DoWithError(func() (err error) {
+		// Start workers
+		ch := make(chan Message)
+		wg := &sync.WaitGroup{}
+		for idx := 0; idx < maxInt(1, o.WorkerCount); idx++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for m := range ch {
+					handleMessage(m, o)
+				}
+			}()
+		}
+
+		// Start consumer
+		if err = c.Start(t.Context(), ch); err != nil {
+			err = errors.Wrapf(err, "astiworker: consuming with %s failed", o.ComponentName)
+		}
+
+		// Wait for workers
+		close(ch)
+		wg.Wait()
+
+		// Update status
+		if err != nil {
+			s.set(StatusCrashed)
+		} else {
+			s.set(StatusStopped)
+			w.UnregisterComponent(cn)
+		}
+		return
+	})
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func handleMessage(m Message, o ConsumeOptions) {
+	// Default acknowledger
+	if m.Acknowledger == nil {
+		m.Acknowledger = noopAcknowledger{}
+	}
+
+	// Handle
+	err := o.Handler(m)
+	if err != nil {
+		astilog.Error(errors.Wrap(err, "astiworker: handling message failed"))
+	}
+
+	// Manual ack
+	if o.ManualAck {
+		return
+	}
+
+	// Ack
+	if err == nil {
+		if errAck := m.Acknowledger.Ack(); errAck != nil {
+			astilog.Error(errors.Wrap(errAck, "astiworker: acking message failed"))
+		}
+		return
+	}
+
+	// Nack
+	if errNack := m.Acknowledger.Nack(o.RequeueOnError); errNack != nil {
+		astilog.Error(errors.Wrap(errNack, "astiworker: nacking message failed"))
+	}
+}
@@ -0,0 +1,130 @@
+package astiworker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull is returned when publishing in a full queue
+var ErrQueueFull = errors.New("astiworker: queue is full")
+
+// QueueConsumer represents an in-process queue consumer
+// Nacked messages are requeued at the front of the queue if requested
+type QueueConsumer struct {
+	cNotify chan bool
+	m       *sync.Mutex // Locks ms
+	maxSize int
+	ms      []Message
+}
+
+// NewQueueConsumer creates a new in-process queue consumer
+// If maxSize > 0, Publish returns ErrQueueFull when the queue contains maxSize messages
+func NewQueueConsumer(maxSize int) *QueueConsumer {
+	return &QueueConsumer{
+		cNotify: make(chan bool, 1),
+		m:       &sync.Mutex{},
+		maxSize: maxSize,
+	}
+}
+
+// Publish publishes a message in the queue
+func (q *QueueConsumer) Publish(body []byte, routingKey string) error {
+	// Lock
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	// Queue is full
+	if q.maxSize > 0 && len(q.ms) >= q.maxSize {
+		return ErrQueueFull
+	}
+
+	// Append message
+	q.ms = append(q.ms, q.newMessage(body, routingKey))
+	q.notify()
+	return nil
+}
+
+// Len returns the number of messages in the queue
+func (q *QueueConsumer) Len() int {
+	q.m.Lock()
+	defer q.m.Unlock()
+	return len(q.ms)
+}
+
+func (q *QueueConsumer) newMessage(body []byte, routingKey string) (m Message) {
+	m = Message{
+		Body:       body,
+		RoutingKey: routingKey,
+	}
+	m.Acknowledger = queueAcknowledger{
+		m: m,
+		q: q,
+	}
+	return
+}
+
+// Assumes the lock is held
+func (q *QueueConsumer) notify() {
+	select {
+	case q.cNotify <- true:
+	default:
+	}
+}
+
+func (q *QueueConsumer) requeue(m Message) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.ms = append([]Message{m}, q.ms...)
+	q.notify()
+}
+
+func (q *QueueConsumer) pop() (m Message, ok bool) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	if ok = len(q.ms) > 0; ok {
+		m = q.ms[0]
+		q.ms = q.ms[1:]
+	}
+	return
+}
+
+// Start implements the Consumer interface
+func (q *QueueConsumer) Start(ctx context.Context, ch chan<- Message) error {
+	for {
+		// Get message
+		m, ok := q.pop()
+		if !ok {
+			select {
+			case <-q.cNotify:
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		// Send message
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			q.requeue(m)
+			return nil
+		}
+	}
+}
+
+type queueAcknowledger struct {
+	m Message
+	q *QueueConsumer
+}
+
+// Ack implements the Acknowledger interface
+func (a queueAcknowledger) Ack() error { return nil }
+
+// Nack implements the Acknowledger interface
+func (a queueAcknowledger) Nack(requeue bool) error {
+	if requeue {
+		a.q.requeue(a.m)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package astiworker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// ReaderConsumer represents a consumer reading line-delimited JSON messages
+// Lines that are not valid JSON are skipped. Since messages can't be requeued, acking is a no-op.
+type ReaderConsumer struct {
+	open func(ctx context.Context, fn func(r io.Reader) error) error
+}
+
+// NewReaderConsumer creates a new consumer reading line-delimited JSON messages from a reader
+// If r implements io.Closer, it is closed when the context is done
+func NewReaderConsumer(r io.Reader) *ReaderConsumer {
+	return &ReaderConsumer{open: func(ctx context.Context, fn func(r io.Reader) error) error {
+		return fn(r)
+	}}
+}
+
+// NewFileConsumer creates a new consumer reading line-delimited JSON messages from a file
+func NewFileConsumer(path string) *ReaderConsumer {
+	return &ReaderConsumer{open: func(ctx context.Context, fn func(r io.Reader) error) (err error) {
+		// Open file
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			err = errors.Wrapf(err, "astiworker: opening %s failed", path)
+			return
+		}
+		defer f.Close()
+
+		// Read
+		return fn(f)
+	}}
+}
+
+// NewUnixSocketConsumer creates a new consumer listening on a unix socket and reading line-delimited JSON messages
+// from each connection
+func NewUnixSocketConsumer(path string) *ReaderConsumer {
+	return &ReaderConsumer{open: func(ctx context.Context, fn func(r io.Reader) error) (err error) {
+		// Listen
+		var l net.Listener
+		if l, err = net.Listen("unix", path); err != nil {
+			err = errors.Wrapf(err, "astiworker: listening on %s failed", path)
+			return
+		}
+
+		// Close listener when context is done
+		go func() {
+			<-ctx.Done()
+			l.Close()
+		}()
+
+		// Accept
+		wg := &sync.WaitGroup{}
+		defer wg.Wait()
+		for {
+			// Accept connection
+			var c net.Conn
+			if c, err = l.Accept(); err != nil {
+				if ctx.Err() != nil {
+					err = nil
+					return
+				}
+				err = errors.Wrapf(err, "astiworker: accepting on %s failed", path)
+				return
+			}
+
+			// Read connection
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer c.Close()
+				if err := fn(c); err != nil {
+					astilog.Error(errors.Wrapf(err, "astiworker: reading connection on %s failed", path))
+				}
+			}()
+		}
+	}}
+}
+
+// Start implements the Consumer interface
+func (c *ReaderConsumer) Start(ctx context.Context, ch chan<- Message) error {
+	return c.open(ctx, func(r io.Reader) (err error) {
+		// Close reader when context is done
+		if cl, ok := r.(io.Closer); ok {
+			cDone := make(chan bool)
+			defer close(cDone)
+			go func() {
+				select {
+				case <-ctx.Done():
+					cl.Close()
+				case <-cDone:
+				}
+			}()
+		}
+
+		// Loop through lines
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			// Get line
+			b := bytes.TrimSpace(s.Bytes())
+			if len(b) == 0 {
+				continue
+			}
+
+			// Validate JSON
+			if !json.Valid(b) {
+				astilog.Errorf("astiworker: skipping invalid json line %s", b)
+				continue
+			}
+
+			// Send message
+			select {
+			case ch <- Message{Body: append([]byte(nil), b...)}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// Check error
+		if err = s.Err(); err != nil && ctx.Err() == nil {
+			err = errors.Wrap(err, "astiworker: scanning failed")
+			return
+		}
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package astiworker
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_ConsumeFrom(t *testing.T) {
+	// Init
+	w := NewWorker()
+	var m sync.Mutex
+	var o []string
+	var failed bool
+	wg := &sync.WaitGroup{}
+	wg.Add(5)
+
+	// Queue
+	q := NewQueueConsumer(3)
+	assert.NoError(t, q.Publish([]byte("1"), "k"))
+	assert.NoError(t, q.Publish([]byte("2"), "k"))
+	assert.NoError(t, q.Publish([]byte("3"), "k"))
+	assert.Equal(t, ErrQueueFull, q.Publish([]byte("4"), "k"))
+	w.ConsumeFrom(q, ConsumeOptions{
+		Handler: func(msg Message) error {
+			m.Lock()
+			defer m.Unlock()
+			if string(msg.Body) == "2" && !failed {
+				failed = true
+				return errors.New("failed")
+			}
+			o = append(o, msg.RoutingKey+string(msg.Body))
+			wg.Done()
+			return nil
+		},
+		RequeueOnError: true,
+		WorkerCount:    2,
+	})
+
+	// Reader
+	w.ConsumeFrom(NewReaderConsumer(strings.NewReader("{\"a\":1}\ninvalid\n\n[2]\n")), ConsumeOptions{
+		Handler: func(msg Message) error {
+			m.Lock()
+			defer m.Unlock()
+			o = append(o, string(msg.Body))
+			wg.Done()
+			return nil
+		},
+	})
+
+	// Wait
+	chanDone := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(chanDone)
+	}()
+	select {
+	case <-chanDone:
+	case <-time.After(time.Second):
+		t.Fatal("messages were not consumed")
+	}
+	w.Stop()
+	assert.NoError(t, w.Wait())
+	sort.Strings(o)
+	assert.Equal(t, []string{"[2]", "k1", "k2", "k3", "{\"a\":1}"}, o)
+	assert.Equal(t, 0, q.Len())
+}
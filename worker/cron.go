@@ -0,0 +1,152 @@
+package astiworker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cronSchedule represents a parsed 5-field cron expression (minute hour day-of-month month day-of-week)
+type cronSchedule struct {
+	dom, dow, hour, minute, month map[int]bool
+	domStar, dowStar              bool
+}
+
+var cronMacros = map[string]string{
+	"@annually": "0 0 1 1 *",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+	"@midnight": "0 0 * * *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@yearly":   "0 0 1 1 *",
+}
+
+func parseCron(expr string) (c cronSchedule, err error) {
+	// Replace macros
+	if v, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		expr = v
+	}
+
+	// Split fields
+	fs := strings.Fields(expr)
+	if len(fs) != 5 {
+		err = fmt.Errorf("astiworker: cron expression %s should have 5 fields, not %d", expr, len(fs))
+		return
+	}
+
+	// Parse fields
+	for _, v := range []struct {
+		f        string
+		min, max int
+		name     string
+		s        *map[int]bool
+	}{
+		{f: fs[0], min: 0, max: 59, name: "minute", s: &c.minute},
+		{f: fs[1], min: 0, max: 23, name: "hour", s: &c.hour},
+		{f: fs[2], min: 1, max: 31, name: "day of month", s: &c.dom},
+		{f: fs[3], min: 1, max: 12, name: "month", s: &c.month},
+		{f: fs[4], min: 0, max: 7, name: "day of week", s: &c.dow},
+	} {
+		if *v.s, err = parseCronField(v.f, v.min, v.max); err != nil {
+			err = errors.Wrapf(err, "astiworker: parsing %s field of cron expression %s failed", v.name, expr)
+			return
+		}
+	}
+	c.domStar = strings.HasPrefix(fs[2], "*")
+	c.dowStar = strings.HasPrefix(fs[4], "*")
+
+	// Sunday can be either 0 or 7
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	return
+}
+
+func parseCronField(f string, min, max int) (s map[int]bool, err error) {
+	s = make(map[int]bool)
+	for _, p := range strings.Split(f, ",") {
+		// Get step
+		step := 1
+		if idx := strings.Index(p, "/"); idx > -1 {
+			if step, err = strconv.Atoi(p[idx+1:]); err != nil || step <= 0 {
+				err = fmt.Errorf("astiworker: invalid step in %s", p)
+				return
+			}
+			p = p[:idx]
+		}
+
+		// Get range
+		start, end := min, max
+		if p != "*" {
+			if idx := strings.Index(p, "-"); idx > -1 {
+				if start, err = strconv.Atoi(p[:idx]); err != nil {
+					err = errors.Wrapf(err, "astiworker: atoi of %s failed", p[:idx])
+					return
+				}
+				if end, err = strconv.Atoi(p[idx+1:]); err != nil {
+					err = errors.Wrapf(err, "astiworker: atoi of %s failed", p[idx+1:])
+					return
+				}
+			} else {
+				if start, err = strconv.Atoi(p); err != nil {
+					err = errors.Wrapf(err, "astiworker: atoi of %s failed", p)
+					return
+				}
+				end = start
+				if step > 1 {
+					end = max
+				}
+			}
+		}
+
+		// Validate range
+		if start < min || end > max || start > end {
+			err = fmt.Errorf("astiworker: range %d-%d is not within %d-%d", start, end, min, max)
+			return
+		}
+
+		// Add values
+		for i := start; i <= end; i += step {
+			s[i] = true
+		}
+	}
+	return
+}
+
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time strictly after t matching the schedule, or the zero time if there's none within 5 years
+func (c cronSchedule) next(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location()).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
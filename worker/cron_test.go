@@ -0,0 +1,34 @@
+package astiworker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCron(t *testing.T) {
+	// Invalid
+	_, err := parseCron("* * *")
+	assert.Error(t, err)
+	_, err = parseCron("60 * * * *")
+	assert.Error(t, err)
+
+	// Next
+	n := time.Date(2020, 1, 31, 23, 58, 30, 0, time.UTC)
+	for _, v := range []struct {
+		e string
+		n time.Time
+	}{
+		{e: "* * * * *", n: time.Date(2020, 1, 31, 23, 59, 0, 0, time.UTC)},
+		{e: "*/15 * * * *", n: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{e: "30 9-17/4 * * 1-5", n: time.Date(2020, 2, 3, 9, 30, 0, 0, time.UTC)},
+		{e: "0 0 29 2 *", n: time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{e: "0 12 15 * 0", n: time.Date(2020, 2, 2, 12, 0, 0, 0, time.UTC)},
+		{e: "@monthly", n: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		c, err := parseCron(v.e)
+		assert.NoError(t, err)
+		assert.Equal(t, v.n, c.next(n), v.e)
+	}
+}
@@ -1,7 +1,9 @@
 package astiworker
 
 import (
+	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 
 	astilog "github.com/asticode/go-astilog"
@@ -9,61 +11,265 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Dial states
+const (
+	DialStateConnected    = "connected"
+	DialStateConnecting   = "connecting"
+	DialStateDisconnected = "disconnected"
+)
+
 // DialOptions represents dial options
 type DialOptions struct {
-	Addr        string
-	Client      *astiws.Client
-	Header      http.Header
-	OnDial      func() error
-	OnReadError func(err error)
+	Addr string
+	// Backoff between attempts starts at BackoffMin and doubles up to BackoffMax. It is reset once connected.
+	// BackoffJitter is the fraction (between 0 and 1) of the backoff that is randomized.
+	// Defaults to 5s and BackoffMin.
+	BackoffJitter float64
+	BackoffMax    time.Duration
+	BackoffMin    time.Duration
+	Client        *astiws.Client
+	// ComponentName is the name under which the dialer is registered. Defaults to Addr.
+	ComponentName string
+	Header        http.Header
+	// If MaxAttempts > 0, the dialer gives up after MaxAttempts consecutive failed attempts
+	MaxAttempts   int
+	OnDial        func() error
+	OnReadError   func(err error)
+	OnStateChange func(state string)
+	// Task name defaults to the component name
+	Task TaskOptions
+}
+
+// Dialer represents an object that dials and reads on a websocket, and reconnects when needed
+type Dialer struct {
+	attempts    int
+	connectedAt time.Time
+	givenUp     bool
+	m           *sync.Mutex // Locks attempts, connectedAt, givenUp, state and stopped
+	o           DialOptions
+	state       string
+	stopped     bool
+}
+
+func newDialer(o DialOptions) *Dialer {
+	return &Dialer{
+		m:     &sync.Mutex{},
+		o:     o,
+		state: DialStateConnecting,
+	}
+}
+
+// Attempts returns the number of consecutive failed attempts
+func (d *Dialer) Attempts() int {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.attempts
+}
+
+// State returns the dialer state
+func (d *Dialer) State() string {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.state
+}
+
+// Uptime returns for how long the dialer has been connected, or 0 if it's not connected
+func (d *Dialer) Uptime() time.Duration {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.state != DialStateConnected {
+		return 0
+	}
+	return time.Since(d.connectedAt)
+}
+
+// Status implements the Component interface
+func (d *Dialer) Status() string {
+	d.m.Lock()
+	defer d.m.Unlock()
+	switch {
+	case d.givenUp:
+		return StatusCrashed
+	case d.stopped:
+		return StatusStopped
+	case d.state == DialStateConnected:
+		return StatusRunning
+	default:
+		return StatusStarting
+	}
+}
+
+func (d *Dialer) setState(s string) {
+	// Update state
+	d.m.Lock()
+	if d.state == s {
+		d.m.Unlock()
+		return
+	}
+	d.state = s
+	if s == DialStateConnected {
+		d.attempts = 0
+		d.connectedAt = time.Now()
+	}
+	d.m.Unlock()
+
+	// Custom callback
+	if d.o.OnStateChange != nil {
+		d.o.OnStateChange(s)
+	}
+}
+
+// fail returns the backoff to apply as well as whether the dialer should give up
+// If attempt is true, the number of failed attempts is incremented
+func (d *Dialer) fail(attempt bool) (backoff time.Duration, giveUp bool) {
+	// Lock
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	// Increment attempts
+	if attempt {
+		d.attempts++
+	}
+	if d.o.MaxAttempts > 0 && d.attempts >= d.o.MaxAttempts {
+		d.givenUp = true
+		giveUp = true
+		return
+	}
+
+	// Compute backoff
+	backoff = d.o.BackoffMin
+	for idx := 1; idx < d.attempts && backoff < d.o.BackoffMax; idx++ {
+		backoff *= 2
+	}
+	backoff = minDuration(backoff, d.o.BackoffMax)
+
+	// Add jitter
+	if d.o.BackoffJitter > 0 {
+		j := time.Duration(d.o.BackoffJitter * float64(backoff))
+		backoff = backoff - j + time.Duration(rand.Int63n(int64(2*j)+1))
+	}
+	return
 }
 
 // Dial dials with options
 // It's the responsibility of the caller to close the Client
-func (w *Worker) Dial(o DialOptions) {
+func (w *Worker) Dial(o DialOptions) (d *Dialer) {
+	// Default options
+	if o.BackoffMin <= 0 {
+		o.BackoffMin = 5 * time.Second
+	}
+	if o.BackoffMax < o.BackoffMin {
+		o.BackoffMax = o.BackoffMin
+	}
+	if o.BackoffJitter < 0 {
+		o.BackoffJitter = 0
+	} else if o.BackoffJitter > 1 {
+		o.BackoffJitter = 1
+	}
+
+	// Create dialer
+	d = newDialer(o)
+
+	// Register component
+	cn := o.ComponentName
+	if cn == "" {
+		cn = o.Addr
+	}
+	n := w.RegisterComponent(ComponentTypeDialer, cn, d)
+
 	// Execute in a task
-	w.NewTask().Do(func() {
+	if o.Task.Name == "" {
+		o.Task.Name = cn
+	}
+	t := w.NewTaskWithOptions(o.Task)
+	t.DoWithError(func() (err error) {
 		// Dial
+		chanGiveUp := make(chan error, 1)
 		go func() {
-			const sleepError = 5 * time.Second
+			// Sleep handles backoff and returns false if the dialer should stop
+			sleep := func(err error, attempt bool) bool {
+				// Get backoff
+				backoff, giveUp := d.fail(attempt)
+				d.setState(DialStateDisconnected)
+				if giveUp {
+					chanGiveUp <- errors.Wrapf(err, "astiworker: giving up dialing %s after %d attempts", o.Addr, o.MaxAttempts)
+					return false
+				}
+
+				// Sleep
+				astilog.Debugf("astiworker: retrying dialing %s in %s", o.Addr, backoff)
+				select {
+				case <-time.After(backoff):
+					return true
+				case <-t.Context().Done():
+					return false
+				}
+			}
+
 			for {
 				// Check context error
-				if w.ctx.Err() != nil {
+				if t.Context().Err() != nil {
 					break
 				}
 
 				// Dial
+				d.setState(DialStateConnecting)
 				astilog.Infof("astiworker: dialing %s", o.Addr)
 				if err := o.Client.DialWithHeaders(o.Addr, o.Header); err != nil {
-					astilog.Error(errors.Wrapf(err, "astiworker: dialing %s failed", o.Addr))
-					time.Sleep(sleepError)
+					err = errors.Wrapf(err, "astiworker: dialing %s failed", o.Addr)
+					astilog.Error(err)
+					if !sleep(err, true) {
+						return
+					}
 					continue
 				}
 
 				// Custom callback
 				if o.OnDial != nil {
 					if err := o.OnDial(); err != nil {
-						astilog.Error(errors.Wrapf(err, "astiworker: custom on dial callback on %s failed", o.Addr))
-						time.Sleep(sleepError)
+						err = errors.Wrapf(err, "astiworker: custom on dial callback on %s failed", o.Addr)
+						astilog.Error(err)
+						if !sleep(err, true) {
+							return
+						}
 						continue
 					}
 				}
 
 				// Read
+				d.setState(DialStateConnected)
 				if err := o.Client.Read(); err != nil {
 					if o.OnReadError != nil {
 						o.OnReadError(err)
 					} else {
 						astilog.Error(errors.Wrapf(err, "astiworker: reading on %s failed", o.Addr))
 					}
-					time.Sleep(sleepError)
+					if !sleep(err, false) {
+						return
+					}
 					continue
 				}
+				d.setState(DialStateDisconnected)
 			}
 		}()
 
-		// Wait for context to be done
-		<-w.ctx.Done()
-	})
+		// Wait for context to be done or dialer to give up
+		select {
+		case <-t.Context().Done():
+		case err = <-chanGiveUp:
+		}
 
+		// Update status
+		d.m.Lock()
+		d.stopped = true
+		d.m.Unlock()
+		d.setState(DialStateDisconnected)
+
+		// Unregister component unless the dialer has given up
+		if err == nil {
+			w.UnregisterComponent(n)
+		}
+		return
+	})
+	return
 }
@@ -0,0 +1,53 @@
+package astiworker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astiws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialer_Fail(t *testing.T) {
+	// Exponential backoff
+	d := newDialer(DialOptions{BackoffMax: 5 * time.Second, BackoffMin: time.Second, MaxAttempts: 5})
+	for _, e := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		b, g := d.fail(true)
+		assert.False(t, g)
+		assert.Equal(t, e, b)
+	}
+	_, g := d.fail(true)
+	assert.True(t, g)
+	assert.Equal(t, StatusCrashed, d.Status())
+
+	// Connected
+	d = newDialer(DialOptions{BackoffJitter: 0.5, BackoffMax: 4 * time.Second, BackoffMin: 4 * time.Second})
+	d.fail(true)
+	d.setState(DialStateConnected)
+	assert.Equal(t, 0, d.Attempts())
+	assert.Equal(t, StatusRunning, d.Status())
+	b, _ := d.fail(false)
+	assert.True(t, b >= 2*time.Second && b <= 6*time.Second)
+}
+
+func TestWorker_Dial(t *testing.T) {
+	// Jitter is clamped
+	w := NewWorker()
+	defer w.Stop()
+	for _, v := range []struct {
+		e float64
+		j float64
+	}{
+		{e: 0, j: -1},
+		{e: 0.5, j: 0.5},
+		{e: 1, j: 3},
+	} {
+		d := w.Dial(DialOptions{
+			Addr:          "ws://127.0.0.1:1",
+			BackoffJitter: v.j,
+			Client:        astiws.NewClient(astiws.ClientConfiguration{}),
+			MaxAttempts:   1,
+		})
+		assert.Equal(t, v.e, d.o.BackoffJitter)
+	}
+}
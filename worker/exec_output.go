@@ -0,0 +1,170 @@
+package astiworker
+
+import (
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astitools/exec"
+)
+
+// Output streams
+const (
+	OutputStreamStderr = "stderr"
+	OutputStreamStdout = "stdout"
+)
+
+// ExecOutputOptions represents exec output options
+type ExecOutputOptions struct {
+	// If BufferSize > 0, the last BufferSize lines are kept in memory and can be retrieved with ExecHandler.Output()
+	BufferSize int
+	// If Capture is true, stdout and stderr are captured and can be streamed with ExecHandler.Subscribe() even if
+	// BufferSize == 0 and Log is false
+	Capture bool
+	// If Log is true, lines are forwarded to astilog with LogPrefix. LogPrefix defaults to "<name>: ".
+	Log       bool
+	LogPrefix string
+	// SubscriberBufferSize is the size of each subscriber's channel. When it's full, lines are dropped for that
+	// subscriber. Defaults to 100.
+	SubscriberBufferSize int
+}
+
+func (o ExecOutputOptions) enabled() bool {
+	return o.BufferSize > 0 || o.Capture || o.Log
+}
+
+// ExecOutputLine represents a line written by an exec'd process
+type ExecOutputLine struct {
+	At     time.Time
+	Line   string
+	Stream string
+}
+
+type execOutput struct {
+	b      []ExecOutputLine
+	closed bool
+	idx    int
+	m      *sync.Mutex // Locks all attributes
+	o      ExecOutputOptions
+	prefix string
+	subID  int
+	subs   map[int]chan ExecOutputLine
+}
+
+func newExecOutput(name string, o ExecOutputOptions) (eo *execOutput) {
+	eo = &execOutput{
+		m:      &sync.Mutex{},
+		o:      o,
+		prefix: o.LogPrefix,
+		subs:   make(map[int]chan ExecOutputLine),
+	}
+	if eo.prefix == "" {
+		eo.prefix = name + ": "
+	}
+	if eo.o.SubscriberBufferSize <= 0 {
+		eo.o.SubscriberBufferSize = 100
+	}
+	return
+}
+
+func (eo *execOutput) adaptCmd(cmd *exec.Cmd) (ws []*astiexec.StdWriter) {
+	for _, v := range []struct {
+		s   string
+		set func(w *astiexec.StdWriter)
+	}{
+		{s: OutputStreamStdout, set: func(w *astiexec.StdWriter) { cmd.Stdout = w }},
+		{s: OutputStreamStderr, set: func(w *astiexec.StdWriter) { cmd.Stderr = w }},
+	} {
+		s := v.s
+		w := astiexec.NewStdWriter(func(i []byte) { eo.add(s, string(i)) })
+		v.set(w)
+		ws = append(ws, w)
+	}
+	return
+}
+
+func (eo *execOutput) add(stream, line string) {
+	// Create line
+	l := ExecOutputLine{
+		At:     time.Now(),
+		Line:   line,
+		Stream: stream,
+	}
+
+	// Log
+	if eo.o.Log {
+		if stream == OutputStreamStderr {
+			astilog.Error(eo.prefix + line)
+		} else {
+			astilog.Info(eo.prefix + line)
+		}
+	}
+
+	// Lock
+	eo.m.Lock()
+	defer eo.m.Unlock()
+
+	// Add to ring buffer
+	if eo.o.BufferSize > 0 {
+		if len(eo.b) < eo.o.BufferSize {
+			eo.b = append(eo.b, l)
+		} else {
+			eo.b[eo.idx] = l
+			eo.idx = (eo.idx + 1) % eo.o.BufferSize
+		}
+	}
+
+	// Send to subscribers
+	for _, c := range eo.subs {
+		select {
+		case c <- l:
+		default:
+		}
+	}
+}
+
+func (eo *execOutput) lines() (ls []ExecOutputLine) {
+	eo.m.Lock()
+	defer eo.m.Unlock()
+	ls = make([]ExecOutputLine, 0, len(eo.b))
+	ls = append(ls, eo.b[eo.idx:]...)
+	ls = append(ls, eo.b[:eo.idx]...)
+	return
+}
+
+func (eo *execOutput) subscribe() (<-chan ExecOutputLine, func()) {
+	// Lock
+	eo.m.Lock()
+	defer eo.m.Unlock()
+
+	// Already closed
+	c := make(chan ExecOutputLine, eo.o.SubscriberBufferSize)
+	if eo.closed {
+		close(c)
+		return c, func() {}
+	}
+
+	// Add subscriber
+	eo.subID++
+	id := eo.subID
+	eo.subs[id] = c
+	return c, func() {
+		eo.m.Lock()
+		defer eo.m.Unlock()
+		if _, ok := eo.subs[id]; ok {
+			close(c)
+			delete(eo.subs, id)
+		}
+	}
+}
+
+func (eo *execOutput) close() {
+	eo.m.Lock()
+	defer eo.m.Unlock()
+	eo.closed = true
+	for id, c := range eo.subs {
+		close(c)
+		delete(eo.subs, id)
+	}
+}
@@ -5,103 +5,396 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astitools/exec"
 	"github.com/pkg/errors"
 )
 
 // Statuses
 const (
-	StatusCrashed = "crashed"
-	StatusRunning = "running"
-	StatusStopped = "stopped"
+	StatusCrashed    = "crashed"
+	StatusRestarting = "restarting"
+	StatusRunning    = "running"
+	StatusStarting   = "starting"
+	StatusStopped    = "stopped"
+)
+
+// Restart policies
+const (
+	RestartPolicyAlways    = "always"
+	RestartPolicyNever     = "never"
+	RestartPolicyOnFailure = "on-failure"
 )
 
 // ExecHandler represents an object capable of handling the execution of a cmd
 type ExecHandler interface {
+	// Output returns the last lines written by the process if ExecOutputOptions.BufferSize > 0
+	Output() []ExecOutputLine
+	Restarts() int
 	Status() string
 	Stop()
+	// Subscribe returns a channel receiving lines written by the process and a func to unsubscribe. The channel is
+	// closed once the process is not supervised anymore or if output is not captured.
+	Subscribe() (<-chan ExecOutputLine, func())
 }
 
 type defaultExecHandler struct {
-	cancel  context.CancelFunc
-	ctx     context.Context
-	err     error
-	o       sync.Once
-	stopped bool
+	cancel   context.CancelFunc
+	ctx      context.Context
+	m        *sync.Mutex // Locks restarts and status
+	o        sync.Once
+	output   *execOutput
+	restarts int
+	status   string
 }
 
-func (h *defaultExecHandler) Status() string {
-	if h.ctx.Err() != nil {
-		if h.stopped || h.err == nil {
-			return StatusStopped
-		}
-		return StatusCrashed
+func newDefaultExecHandler(ctx context.Context, o ExecOptions) (h *defaultExecHandler) {
+	h = &defaultExecHandler{
+		m:      &sync.Mutex{},
+		status: StatusStarting,
+	}
+	h.ctx, h.cancel = context.WithCancel(ctx)
+	if o.Output.enabled() {
+		h.output = newExecOutput(o.Name, o.Output)
 	}
-	return StatusRunning
+	return
+}
+
+// Output implements the ExecHandler interface
+func (h *defaultExecHandler) Output() []ExecOutputLine {
+	if h.output == nil {
+		return []ExecOutputLine{}
+	}
+	return h.output.lines()
+}
+
+// Restarts implements the ExecHandler interface
+func (h *defaultExecHandler) Restarts() int {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.restarts
 }
 
+// Status implements the ExecHandler interface
+func (h *defaultExecHandler) Status() string {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.status
+}
+
+// Stop implements the ExecHandler interface
 func (h *defaultExecHandler) Stop() {
 	h.o.Do(func() {
 		h.cancel()
-		h.stopped = true
 	})
 }
 
+// Subscribe implements the ExecHandler interface
+func (h *defaultExecHandler) Subscribe() (<-chan ExecOutputLine, func()) {
+	if h.output == nil {
+		c := make(chan ExecOutputLine)
+		close(c)
+		return c, func() {}
+	}
+	return h.output.subscribe()
+}
+
+func (h *defaultExecHandler) setStatus(s string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.status = s
+}
+
+func (h *defaultExecHandler) incRestarts() int {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.restarts++
+	return h.restarts
+}
+
+// ExecOptions represents exec options
 type ExecOptions struct {
 	Args       []string
 	CmdAdapter func(cmd *exec.Cmd, h ExecHandler) error
-	Name       string
-	StopFunc   func(cmd *exec.Cmd) error
+	// ComponentName is the name under which the handler is registered. Defaults to Name.
+	ComponentName string
+	Name          string
+	// Output is captured before CmdAdapter is executed, therefore CmdAdapter can override cmd.Stdout and cmd.Stderr
+	Output   ExecOutputOptions
+	StopFunc func(cmd *exec.Cmd) error
+	// If StopTimeout > 0, the cmd is sent a SIGTERM (or StopFunc is executed) and is killed if it hasn't exited after
+	// StopTimeout. Otherwise the cmd is killed right away (or StopFunc is executed).
+	StopTimeout time.Duration
+	Supervisor  ExecSupervisorOptions
+	// Task name defaults to the component name
+	Task TaskOptions
+}
+
+// ExecSupervisorOptions represents exec supervisor options
+type ExecSupervisorOptions struct {
+	// Backoff between restarts starts at BackoffMin and doubles up to BackoffMax.
+	// It is reset when the cmd has been running for longer than BackoffMax.
+	// Defaults to 1s and 30s.
+	BackoffMax time.Duration
+	BackoffMin time.Duration
+	// If MaxRestarts > 0, the supervisor gives up when the cmd has been restarted MaxRestarts times within
+	// MaxRestartsWindow. If MaxRestartsWindow is 0, the window is infinite.
+	MaxRestarts       int
+	MaxRestartsWindow time.Duration
+	OnExit            func(h ExecHandler, err error)
+	OnGiveUp          func(h ExecHandler, err error)
+	OnRestart         func(h ExecHandler, restarts int)
+	OnStart           func(h ExecHandler)
+	// Defaults to RestartPolicyNever
+	Policy string
 }
 
 // Exec executes a cmd
 // The process will be stopped when the worker stops
 func (w *Worker) Exec(o ExecOptions) (ExecHandler, error) {
+	// Get component name
+	cn := o.ComponentName
+	if cn == "" {
+		cn = o.Name
+	}
+
+	// Create task
+	if o.Task.Name == "" {
+		o.Task.Name = cn
+	}
+	t := w.NewTaskWithOptions(o.Task)
+
 	// Create handler
-	h := &defaultExecHandler{}
-	h.ctx, h.cancel = context.WithCancel(w.Context())
+	h := newDefaultExecHandler(t.Context(), o)
+
+	// Default supervisor options
+	if o.Supervisor.BackoffMin <= 0 {
+		o.Supervisor.BackoffMin = time.Second
+	}
+	if o.Supervisor.BackoffMax < o.Supervisor.BackoffMin {
+		o.Supervisor.BackoffMax = maxDuration(30*time.Second, o.Supervisor.BackoffMin)
+	}
+
+	// Start
+	r, err := startCmd(o, h)
+	if err != nil {
+		t.Done()
+		return nil, err
+	}
+
+	// Register component
+	n := w.RegisterComponent(ComponentTypeExec, cn, h)
+
+	// Execute in a task
+	t.Do(func() {
+		// Make sure the handler's context is cancelled and subscribers are closed once the cmd is not supervised
+		// anymore
+		defer func() {
+			h.cancel()
+			if h.output != nil {
+				h.output.close()
+			}
+		}()
+
+		// Unregister component once the cmd is not supervised anymore, unless it is supervised and crashed
+		defer func() {
+			if o.Supervisor.Policy == "" || o.Supervisor.Policy == RestartPolicyNever || h.Status() != StatusCrashed {
+				w.UnregisterComponent(n)
+			}
+		}()
+
+		// Loop
+		var backoff time.Duration
+		var restartedAt []time.Time
+		for {
+			// Wait
+			startedAt := time.Now()
+			err := waitCmd(r, o, h)
+
+			// Stopped manually or by the worker
+			if h.ctx.Err() != nil {
+				h.setStatus(StatusStopped)
+				astilog.Infof("astiworker: status is now %s for %s", StatusStopped, strings.Join(r.cmd.Args, " "))
+				if o.Supervisor.OnExit != nil {
+					o.Supervisor.OnExit(h, err)
+				}
+				return
+			}
+
+			// Update status
+			s := StatusStopped
+			if err != nil {
+				s = StatusCrashed
+			}
+			h.setStatus(s)
+			astilog.Infof("astiworker: status is now %s for %s", s, strings.Join(r.cmd.Args, " "))
+
+			// Custom callback
+			if o.Supervisor.OnExit != nil {
+				o.Supervisor.OnExit(h, err)
+			}
+
+			// Check policy
+			if o.Supervisor.Policy != RestartPolicyAlways && (o.Supervisor.Policy != RestartPolicyOnFailure || err == nil) {
+				return
+			}
 
+			// Check max restarts
+			if o.Supervisor.MaxRestarts > 0 {
+				// Remove restarts outside of the window
+				if o.Supervisor.MaxRestartsWindow > 0 {
+					for len(restartedAt) > 0 && time.Since(restartedAt[0]) > o.Supervisor.MaxRestartsWindow {
+						restartedAt = restartedAt[1:]
+					}
+				}
+
+				// Give up
+				if len(restartedAt) >= o.Supervisor.MaxRestarts {
+					astilog.Errorf("astiworker: giving up restarting %s after %d restarts", strings.Join(r.cmd.Args, " "), len(restartedAt))
+					if o.Supervisor.OnGiveUp != nil {
+						o.Supervisor.OnGiveUp(h, err)
+					}
+					return
+				}
+			}
+
+			// Compute backoff
+			if backoff == 0 || time.Since(startedAt) > o.Supervisor.BackoffMax {
+				backoff = o.Supervisor.BackoffMin
+			} else {
+				backoff = minDuration(2*backoff, o.Supervisor.BackoffMax)
+			}
+
+			// Sleep
+			h.setStatus(StatusRestarting)
+			astilog.Infof("astiworker: restarting %s in %s", strings.Join(r.cmd.Args, " "), backoff)
+			select {
+			case <-time.After(backoff):
+			case <-h.ctx.Done():
+				h.setStatus(StatusStopped)
+				return
+			}
+
+			// Restart
+			restartedAt = append(restartedAt, time.Now())
+			n := h.incRestarts()
+			if o.Supervisor.OnRestart != nil {
+				o.Supervisor.OnRestart(h, n)
+			}
+			if r, err = startCmd(o, h); err != nil {
+				h.setStatus(StatusCrashed)
+				astilog.Error(errors.Wrap(err, "astiworker: restarting cmd failed"))
+				if o.Supervisor.OnGiveUp != nil {
+					o.Supervisor.OnGiveUp(h, err)
+				}
+				return
+			}
+		}
+	})
+	return h, nil
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type execRun struct {
+	cmd *exec.Cmd
+	ws  []*astiexec.StdWriter
+}
+
+func startCmd(o ExecOptions, h *defaultExecHandler) (r *execRun, err error) {
 	// Create command
 	cmd := exec.Command(o.Name, o.Args...)
+	r = &execRun{cmd: cmd}
+
+	// Capture output
+	if h.output != nil {
+		r.ws = h.output.adaptCmd(cmd)
+	}
 
 	// Adapt command
 	if o.CmdAdapter != nil {
-		if err := o.CmdAdapter(cmd, h); err != nil {
-			return nil, errors.Wrap(err, "astiworker: adapting cmd failed")
+		if err = o.CmdAdapter(cmd, h); err != nil {
+			err = errors.Wrap(err, "astiworker: adapting cmd failed")
+			return
 		}
 	}
 
 	// Start
 	astilog.Infof("astiworker: starting %s", strings.Join(cmd.Args, " "))
-	if err := cmd.Start(); err != nil {
+	if err = cmd.Start(); err != nil {
 		err = errors.Wrapf(err, "astiworker: executing %s", strings.Join(cmd.Args, " "))
-		return nil, err
+		return
 	}
+	h.setStatus(StatusRunning)
 
-	// Handle context
-	go func() {
-		// Wait for context to be done
-		<-h.ctx.Done()
-
-		// Get stop func
-		f := func() error { return cmd.Process.Kill() }
-		if o.StopFunc != nil {
-			f = func() error { return o.StopFunc(cmd) }
-		}
+	// Custom callback
+	if o.Supervisor.OnStart != nil {
+		o.Supervisor.OnStart(h)
+	}
+	return
+}
 
-		// Stop
-		if err := f(); err != nil {
-			astilog.Error(errors.Wrap(err, "astiworker: stopping cmd failed"))
-			return
+func waitCmd(r *execRun, o ExecOptions, h *defaultExecHandler) (err error) {
+	// Wait in a goroutine
+	cmd := r.cmd
+	chanDone := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		for _, w := range r.ws {
+			w.Close()
 		}
+		chanDone <- err
 	}()
 
-	// Execute in a task
-	w.NewTask().Do(func() {
-		h.err = cmd.Wait()
-		h.cancel()
-		astilog.Infof("astiworker: status is now %s for %s", h.Status(), strings.Join(cmd.Args, " "))
-	})
-	return h, nil
+	// Wait for cmd or context to be done
+	select {
+	case err = <-chanDone:
+		return
+	case <-h.ctx.Done():
+	}
+
+	// Get stop func
+	f := func() error { return cmd.Process.Kill() }
+	if o.StopFunc != nil {
+		f = func() error { return o.StopFunc(cmd) }
+	} else if o.StopTimeout > 0 {
+		f = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	}
+
+	// Stop
+	if errStop := f(); errStop != nil {
+		astilog.Error(errors.Wrap(errStop, "astiworker: stopping cmd failed"))
+	}
+
+	// No timeout
+	if o.StopTimeout <= 0 {
+		return <-chanDone
+	}
+
+	// Wait for cmd to exit or kill it
+	select {
+	case err = <-chanDone:
+	case <-time.After(o.StopTimeout):
+		astilog.Infof("astiworker: %s didn't stop after %s, killing it", strings.Join(cmd.Args, " "), o.StopTimeout)
+		if errKill := cmd.Process.Kill(); errKill != nil {
+			astilog.Error(errors.Wrap(errKill, "astiworker: killing cmd failed"))
+		}
+		err = <-chanDone
+	}
+	return
 }
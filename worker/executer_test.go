@@ -0,0 +1,138 @@
+package astiworker
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_ExecSupervisor(t *testing.T) {
+	// Init
+	w := NewWorker()
+	defer w.Stop()
+
+	// Restart on failure and give up
+	var starts int
+	chanGiveUp := make(chan error)
+	h, err := w.Exec(ExecOptions{
+		Args: []string{"-c", "exit 1"},
+		Name: "sh",
+		Supervisor: ExecSupervisorOptions{
+			BackoffMin:  time.Millisecond,
+			MaxRestarts: 2,
+			OnGiveUp:    func(h ExecHandler, err error) { chanGiveUp <- err },
+			OnStart:     func(h ExecHandler) { starts++ },
+			Policy:      RestartPolicyOnFailure,
+		},
+	})
+	assert.NoError(t, err)
+	select {
+	case err = <-chanGiveUp:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("supervisor didn't give up")
+	}
+	assert.Equal(t, 2, h.Restarts())
+	assert.Equal(t, 3, starts)
+	assert.Equal(t, StatusCrashed, h.Status())
+
+	// Graceful stop
+	chanExit := make(chan error)
+	h, err = w.Exec(ExecOptions{
+		Args:        []string{"10"},
+		Name:        "sleep",
+		StopTimeout: time.Second,
+		Supervisor: ExecSupervisorOptions{
+			OnExit: func(h ExecHandler, err error) { chanExit <- err },
+			Policy: RestartPolicyAlways,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRunning, h.Status())
+	h.Stop()
+	select {
+	case <-chanExit:
+	case <-time.After(time.Second):
+		t.Fatal("cmd didn't stop")
+	}
+	assert.Equal(t, StatusStopped, h.Status())
+	assert.Equal(t, 0, h.Restarts())
+}
+
+func TestWorker_ExecComponent(t *testing.T) {
+	// Init
+	w := NewWorker()
+	defer w.Stop()
+	waitForComponents := func(n int) []ComponentStatus {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if ss := w.ComponentStatuses(); len(ss) == n {
+				return ss
+			}
+		}
+		t.Fatalf("expected %d components", n)
+		return nil
+	}
+
+	// One-off cmds are unregistered once they exit, even if they crashed
+	_, err := w.Exec(ExecOptions{
+		Args: []string{"-c", "exit 1"},
+		Name: "sh",
+	})
+	assert.NoError(t, err)
+	waitForComponents(0)
+	assert.True(t, w.IsHealthy())
+
+	// Supervised cmds that crashed are kept
+	chanGiveUp := make(chan bool)
+	_, err = w.Exec(ExecOptions{
+		Args: []string{"-c", "exit 1"},
+		Name: "sh",
+		Supervisor: ExecSupervisorOptions{
+			BackoffMin:  time.Millisecond,
+			MaxRestarts: 1,
+			OnGiveUp:    func(h ExecHandler, err error) { close(chanGiveUp) },
+			Policy:      RestartPolicyOnFailure,
+		},
+	})
+	assert.NoError(t, err)
+	select {
+	case <-chanGiveUp:
+	case <-time.After(time.Second):
+		t.Fatal("supervisor didn't give up")
+	}
+	assert.False(t, w.IsHealthy())
+	assert.Equal(t, []ComponentStatus{{Name: "sh", Status: StatusCrashed, Type: ComponentTypeExec}}, waitForComponents(1))
+}
+
+func TestWorker_ExecOutput(t *testing.T) {
+	// Init
+	w := NewWorker()
+	defer w.Stop()
+
+	// Exec
+	chanExit := make(chan bool)
+	h, err := w.Exec(ExecOptions{
+		Args: []string{"-c", "read l; echo 1; echo 2; echo 3 >&2; printf 4"},
+		CmdAdapter: func(cmd *exec.Cmd, h ExecHandler) error {
+			cmd.Stdin = strings.NewReader("\n")
+			return nil
+		},
+		Name:   "sh",
+		Output: ExecOutputOptions{BufferSize: 3},
+		Supervisor: ExecSupervisorOptions{
+			OnExit: func(h ExecHandler, err error) { close(chanExit) },
+		},
+	})
+	assert.NoError(t, err)
+	c, _ := h.Subscribe()
+	var ls []string
+	for l := range c {
+		ls = append(ls, l.Stream+":"+l.Line)
+	}
+	<-chanExit
+	assert.ElementsMatch(t, []string{"stdout:1", "stdout:2", "stderr:3", "stdout:4"}, ls)
+	assert.Len(t, h.Output(), 3)
+}
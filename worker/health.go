@@ -0,0 +1,199 @@
+package astiworker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// Component types
+const (
+	ComponentTypeConsumer = "consumer"
+	ComponentTypeDialer   = "dialer"
+	ComponentTypeExec     = "exec"
+	ComponentTypeSchedule = "schedule"
+	ComponentTypeServer   = "server"
+)
+
+// Component represents a worker component whose status can be monitored
+type Component interface {
+	Status() string
+}
+
+// ComponentFunc represents a func that implements the Component interface
+type ComponentFunc func() string
+
+// Status implements the Component interface
+func (f ComponentFunc) Status() string { return f() }
+
+// ComponentStatus represents a component status
+type ComponentStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Type   string `json:"type"`
+}
+
+type component struct {
+	c Component
+	t string
+}
+
+type statusComponent struct {
+	m *sync.Mutex // Locks s
+	s string
+}
+
+func newStatusComponent(s string) *statusComponent {
+	return &statusComponent{
+		m: &sync.Mutex{},
+		s: s,
+	}
+}
+
+// Status implements the Component interface
+func (c *statusComponent) Status() string {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.s
+}
+
+func (c *statusComponent) set(s string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.s = s
+}
+
+// RegisterComponent registers a component and returns its unique name
+// If the name is already taken, a suffix is added. Built-in components are unregistered once their task ends, unless
+// they crashed while they were meant to run until the worker stops, so that the crash is still reported.
+func (w *Worker) RegisterComponent(t, name string, c Component) string {
+	// Lock
+	w.mc.Lock()
+	defer w.mc.Unlock()
+
+	// Make sure the name is unique
+	n := name
+	for idx := 2; ; idx++ {
+		if _, ok := w.cs[n]; !ok {
+			break
+		}
+		n = name + " #" + strconv.Itoa(idx)
+	}
+
+	// Register
+	w.cs[n] = component{
+		c: c,
+		t: t,
+	}
+	return n
+}
+
+// UnregisterComponent unregisters a component
+func (w *Worker) UnregisterComponent(name string) {
+	w.mc.Lock()
+	defer w.mc.Unlock()
+	delete(w.cs, name)
+}
+
+// ComponentStatuses returns the statuses of all registered components sorted by name
+func (w *Worker) ComponentStatuses() (ss []ComponentStatus) {
+	// Lock
+	w.mc.Lock()
+	defer w.mc.Unlock()
+
+	// Loop through components
+	ss = []ComponentStatus{}
+	for n, c := range w.cs {
+		ss = append(ss, ComponentStatus{
+			Name:   n,
+			Status: c.c.Status(),
+			Type:   c.t,
+		})
+	}
+
+	// Sort
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Name < ss[j].Name })
+	return
+}
+
+// IsHealthy returns whether the worker is running and none of its components has crashed
+func (w *Worker) IsHealthy() bool {
+	if w.isStopping() {
+		return false
+	}
+	for _, s := range w.ComponentStatuses() {
+		if s.Status == StatusCrashed {
+			return false
+		}
+	}
+	return true
+}
+
+// IsReady returns whether the worker is healthy and none of its components is starting or restarting
+func (w *Worker) IsReady() bool {
+	if !w.IsHealthy() {
+		return false
+	}
+	for _, s := range w.ComponentStatuses() {
+		if s.Status == StatusStarting || s.Status == StatusRestarting {
+			return false
+		}
+	}
+	return true
+}
+
+// HealthStatus represents the worker health status
+type HealthStatus struct {
+	Components []ComponentStatus `json:"components"`
+	Healthy    bool              `json:"healthy"`
+	Ready      bool              `json:"ready"`
+}
+
+// HealthStatus returns the worker health status
+func (w *Worker) HealthStatus() HealthStatus {
+	return HealthStatus{
+		Components: w.ComponentStatuses(),
+		Healthy:    w.IsHealthy(),
+		Ready:      w.IsReady(),
+	}
+}
+
+func writeProbe(rw http.ResponseWriter, ok bool) {
+	if !ok {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+// HealthHandler returns an http.Handler serving /healthz, /readyz and a JSON status page on /status
+func (w *Worker) HealthHandler() http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("/healthz", func(rw http.ResponseWriter, r *http.Request) {
+		writeProbe(rw, w.IsHealthy())
+	})
+	m.HandleFunc("/readyz", func(rw http.ResponseWriter, r *http.Request) {
+		writeProbe(rw, w.IsReady())
+	})
+	m.HandleFunc("/status", func(rw http.ResponseWriter, r *http.Request) {
+		s := w.HealthStatus()
+		rw.Header().Set("Content-Type", "application/json")
+		if !s.Healthy {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(rw).Encode(s); err != nil {
+			astilog.Error(errors.Wrap(err, "astiworker: writing health status failed"))
+		}
+	})
+	return m
+}
+
+// ServeHealth serves the health handler on addr
+func (w *Worker) ServeHealth(addr string) {
+	w.Serve(addr, w.HealthHandler())
+}
@@ -0,0 +1,43 @@
+package astiworker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_HealthHandler(t *testing.T) {
+	// Init
+	w := NewWorker()
+	defer w.Stop()
+	c := newStatusComponent(StatusStarting)
+	assert.Equal(t, "c", w.RegisterComponent(ComponentTypeDialer, "c", c))
+	assert.Equal(t, "c #2", w.RegisterComponent(ComponentTypeExec, "c", ComponentFunc(func() string { return StatusRunning })))
+	h := w.HealthHandler()
+
+	// Starting
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	// Running
+	c.set(StatusRunning)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"components":[{"name":"c","status":"running","type":"dialer"},{"name":"c #2","status":"running","type":"exec"}],"healthy":true,"ready":true}`, rec.Body.String())
+
+	// Crashed
+	c.set(StatusCrashed)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
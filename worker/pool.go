@@ -0,0 +1,294 @@
+package astiworker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astitools/stat"
+	"github.com/pkg/errors"
+)
+
+// Pool errors
+var (
+	ErrPoolFull    = errors.New("astiworker: pool queue is full")
+	ErrPoolStopped = errors.New("astiworker: pool is stopped")
+)
+
+// PoolJob represents a job executed by a pool
+type PoolJob func(ctx context.Context) (interface{}, error)
+
+// PoolResult represents the result of a job
+type PoolResult struct {
+	Duration time.Duration
+	Err      error
+	Value    interface{}
+}
+
+// PoolFuture represents the future result of a job
+type PoolFuture struct {
+	c chan bool
+	r PoolResult
+}
+
+func newPoolFuture() *PoolFuture {
+	return &PoolFuture{c: make(chan bool)}
+}
+
+func (f *PoolFuture) done(r PoolResult) {
+	f.r = r
+	close(f.c)
+}
+
+// Done returns a channel closed once the job is done
+func (f *PoolFuture) Done() <-chan bool {
+	return f.c
+}
+
+// Wait blocks until the job is done and returns its result
+func (f *PoolFuture) Wait() PoolResult {
+	<-f.c
+	return f.r
+}
+
+// PoolOptions represents pool options
+type PoolOptions struct {
+	// JobTimeout is the max duration of each job's context. 0 means no timeout.
+	JobTimeout time.Duration
+	// QueueSize is the max number of jobs waiting to be executed. Defaults to WorkerCount.
+	QueueSize int
+	// Task name defaults to "pool"
+	Task TaskOptions
+	// WorkerCount is the max number of jobs executed concurrently. Defaults to 1.
+	WorkerCount int
+}
+
+type poolJob struct {
+	f  *PoolFuture
+	fn PoolJob
+}
+
+// Pool represents a bounded pool of goroutines executing jobs
+// It is bound to a worker task: once the task's context is done, running jobs are cancelled and queued jobs fail with
+// ErrPoolStopped
+type Pool struct {
+	closed     bool
+	m          *sync.RWMutex // Locks closed
+	o          PoolOptions
+	q          chan poolJob
+	statBusy   *astistat.DurationRatioStat
+	statDone   *astistat.IncrementStat
+	statErrors *astistat.IncrementStat
+	t          *Task
+}
+
+// NewPool creates a new pool
+func (w *Worker) NewPool(o PoolOptions) (p *Pool) {
+	// Default options
+	if o.WorkerCount <= 0 {
+		o.WorkerCount = 1
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = o.WorkerCount
+	}
+	if o.Task.Name == "" {
+		o.Task.Name = "pool"
+	}
+
+	// Create pool
+	p = &Pool{
+		m:          &sync.RWMutex{},
+		o:          o,
+		q:          make(chan poolJob, o.QueueSize),
+		statBusy:   astistat.NewDurationRatioStat(),
+		statDone:   astistat.NewIncrementStat(),
+		statErrors: astistat.NewIncrementStat(),
+		t:          w.NewTaskWithOptions(o.Task),
+	}
+
+	// Execute in a task
+	p.t.Do(func() {
+		// Start workers
+		for idx := 0; idx < o.WorkerCount; idx++ {
+			p.t.NewSubTask().Do(p.work)
+		}
+
+		// Wait for context to be done
+		<-p.t.Context().Done()
+
+		// Close
+		p.m.Lock()
+		p.closed = true
+		p.m.Unlock()
+
+		// Wait for workers
+		p.t.Wait()
+
+		// Fail queued jobs
+		for {
+			select {
+			case j := <-p.q:
+				j.f.done(PoolResult{Err: ErrPoolStopped})
+			default:
+				return
+			}
+		}
+	})
+	return
+}
+
+func (p *Pool) work() {
+	for {
+		select {
+		case j := <-p.q:
+			p.exec(j)
+		case <-p.t.Context().Done():
+			return
+		}
+	}
+}
+
+func (p *Pool) exec(j poolJob) {
+	// Pool is stopped
+	if p.t.Context().Err() != nil {
+		j.f.done(PoolResult{Err: ErrPoolStopped})
+		return
+	}
+
+	// Create context
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if p.o.JobTimeout > 0 {
+		ctx, cancel = context.WithTimeout(p.t.Context(), p.o.JobTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(p.t.Context())
+	}
+	defer cancel()
+
+	// Execute
+	p.statBusy.Add(&j)
+	r := PoolResult{}
+	n := time.Now()
+	r.Value, r.Err = j.fn(ctx)
+	r.Duration = time.Since(n)
+	p.statBusy.Done(&j)
+
+	// Update stats
+	p.statDone.Add(1)
+	if r.Err != nil {
+		p.statErrors.Add(1)
+	}
+	j.f.done(r)
+}
+
+// Submit adds a job to the queue and blocks while the queue is full, until ctx is done or the pool is stopped
+func (p *Pool) Submit(ctx context.Context, fn PoolJob) (f *PoolFuture, err error) {
+	// Lock
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	// Pool is stopped
+	if p.closed {
+		err = ErrPoolStopped
+		return
+	}
+
+	// Add job
+	j := poolJob{
+		f:  newPoolFuture(),
+		fn: fn,
+	}
+	select {
+	case p.q <- j:
+		f = j.f
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "astiworker: context error")
+	case <-p.t.Context().Done():
+		err = ErrPoolStopped
+	}
+	return
+}
+
+// TrySubmit adds a job to the queue without blocking and returns ErrPoolFull if the queue is full
+func (p *Pool) TrySubmit(fn PoolJob) (f *PoolFuture, err error) {
+	// Lock
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	// Pool is stopped
+	if p.closed {
+		err = ErrPoolStopped
+		return
+	}
+
+	// Add job
+	j := poolJob{
+		f:  newPoolFuture(),
+		fn: fn,
+	}
+	select {
+	case p.q <- j:
+		f = j.f
+	default:
+		err = ErrPoolFull
+	}
+	return
+}
+
+// Run executes jobs with at most WorkerCount of them running concurrently, waits for them and returns their results in
+// the same order
+func (p *Pool) Run(ctx context.Context, fns ...PoolJob) (rs []PoolResult) {
+	// Submit
+	rs = make([]PoolResult, len(fns))
+	fs := make([]*PoolFuture, len(fns))
+	for idx, fn := range fns {
+		var err error
+		if fs[idx], err = p.Submit(ctx, fn); err != nil {
+			rs[idx] = PoolResult{Err: err}
+		}
+	}
+
+	// Wait
+	for idx, f := range fs {
+		if f != nil {
+			rs[idx] = f.Wait()
+		}
+	}
+	return
+}
+
+// QueueLen returns the number of jobs waiting to be executed
+func (p *Pool) QueueLen() int {
+	return len(p.q)
+}
+
+// AddStats adds pool stats
+func (p *Pool) AddStats(s *astistat.Stater) {
+	// Add busy stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Percentage of time spent executing jobs, summed over workers",
+		Label:       "Busy ratio",
+		Unit:        "%",
+	}, p.statBusy)
+
+	// Add done stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Number of jobs executed per second",
+		Label:       "Jobs",
+		Unit:        "jobs/s",
+	}, p.statDone)
+
+	// Add errors stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Number of jobs that returned an error per second",
+		Label:       "Job errors",
+		Unit:        "errors/s",
+	}, p.statErrors)
+
+	// Add queue stat
+	s.AddStat(astistat.StatMetadata{
+		Description: "Number of jobs waiting to be executed",
+		Label:       "Queue length",
+		Unit:        "jobs",
+	}, astistat.StatHandlerWithoutStart(func(delta time.Duration) interface{} { return p.QueueLen() }))
+}
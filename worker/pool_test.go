@@ -0,0 +1,126 @@
+package astiworker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	// Init
+	w := NewWorker()
+	p := w.NewPool(PoolOptions{
+		JobTimeout:  20 * time.Millisecond,
+		QueueSize:   1,
+		WorkerCount: 2,
+	})
+
+	// Concurrency and results
+	var c, max int32
+	var fns []PoolJob
+	for idx := 0; idx < 6; idx++ {
+		i := idx
+		fns = append(fns, func(ctx context.Context) (interface{}, error) {
+			n := atomic.AddInt32(&c, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			atomic.AddInt32(&c, -1)
+			if i == 3 {
+				return nil, errors.New("test")
+			}
+			return i, nil
+		})
+	}
+	rs := p.Run(context.Background(), fns...)
+	assert.Len(t, rs, 6)
+	for idx, r := range rs {
+		if idx == 3 {
+			assert.EqualError(t, r.Err, "test")
+		} else {
+			assert.NoError(t, r.Err)
+			assert.Equal(t, idx, r.Value)
+		}
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&max))
+
+	// Job timeout
+	f, err := p.Submit(context.Background(), func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, context.DeadlineExceeded, f.Wait().Err)
+
+	w.Stop()
+	assert.NoError(t, w.Wait())
+}
+
+func TestPool_Backpressure(t *testing.T) {
+	// Init
+	w := NewWorker()
+	p := w.NewPool(PoolOptions{
+		QueueSize:   1,
+		WorkerCount: 2,
+	})
+	chanBlock := make(chan bool)
+	chanStarted := make(chan bool, 3)
+	block := func(ctx context.Context) (interface{}, error) {
+		chanStarted <- true
+		select {
+		case <-chanBlock:
+		case <-ctx.Done():
+		}
+		return nil, nil
+	}
+	waitForStart := func(n int) {
+		for idx := 0; idx < n; idx++ {
+			select {
+			case <-chanStarted:
+			case <-time.After(time.Second):
+				t.Fatal("job didn't start")
+			}
+		}
+	}
+
+	// Backpressure
+	f1, _ := p.Submit(context.Background(), block)
+	f2, _ := p.Submit(context.Background(), block)
+	waitForStart(2)
+	f3, err := p.TrySubmit(block)
+	assert.NoError(t, err)
+	_, err = p.TrySubmit(block)
+	assert.Equal(t, ErrPoolFull, err)
+	assert.Equal(t, 1, p.QueueLen())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err = p.Submit(ctx, block)
+	assert.Error(t, err)
+	close(chanBlock)
+	waitForStart(1)
+	f1.Wait()
+	f2.Wait()
+	f3.Wait()
+
+	// Stop
+	chanBlock = make(chan bool)
+	f1, _ = p.Submit(context.Background(), block)
+	f2, _ = p.Submit(context.Background(), block)
+	waitForStart(2)
+	f3, _ = p.Submit(context.Background(), block)
+	w.Stop()
+	assert.NoError(t, w.Wait())
+	assert.NoError(t, f1.Wait().Err)
+	assert.NoError(t, f2.Wait().Err)
+	assert.Equal(t, ErrPoolStopped, f3.Wait().Err)
+	_, err = p.Submit(context.Background(), block)
+	assert.Equal(t, ErrPoolStopped, err)
+}
@@ -0,0 +1,240 @@
+package astiworker
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// Overlap policies
+const (
+	OverlapAllow = "allow"
+	OverlapQueue = "queue"
+	OverlapSkip  = "skip"
+)
+
+// ScheduleOptions represents schedule options
+// Either Cron or Every must be set
+type ScheduleOptions struct {
+	// ComponentName is the name under which the schedule is registered. Defaults to the task name.
+	ComponentName string
+	// Cron is a 5-field cron expression (minute hour day-of-month month day-of-week) evaluated in local time. Macros
+	// such as @hourly or @daily are supported.
+	Cron    string
+	Every   time.Duration
+	Handler func(ctx context.Context) error
+	// HistorySize is the number of runs kept in history. Defaults to 10.
+	HistorySize int
+	// Jitter is the max random delay added to each run
+	Jitter time.Duration
+	// Overlap decides what happens when a run is due while the previous one is still running. Defaults to
+	// OverlapSkip.
+	Overlap string
+	// Task name defaults to "schedule"
+	Task TaskOptions
+}
+
+// ScheduleRun represents a scheduled run
+type ScheduleRun struct {
+	Duration  time.Duration
+	Err       error
+	Skipped   bool
+	StartedAt time.Time
+}
+
+// Schedule represents a recurring task
+type Schedule struct {
+	c         *cronSchedule
+	h         []ScheduleRun
+	m         *sync.Mutex // Locks h, nextRunAt, pending and running
+	nextRunAt time.Time
+	o         ScheduleOptions
+	pending   int
+	running   int
+	t         *Task
+}
+
+// Schedule schedules a recurring task by interval or cron expression
+// Runs are cancelled when the worker stops
+func (w *Worker) Schedule(o ScheduleOptions) (s *Schedule, err error) {
+	// Validate options
+	if o.Handler == nil {
+		err = errors.New("astiworker: handler is mandatory")
+		return
+	}
+	var c *cronSchedule
+	if o.Cron != "" {
+		var cs cronSchedule
+		if cs, err = parseCron(o.Cron); err != nil {
+			err = errors.Wrap(err, "astiworker: parsing cron failed")
+			return
+		}
+		c = &cs
+	} else if o.Every <= 0 {
+		err = errors.New("astiworker: either cron or every must be set")
+		return
+	}
+
+	// Create schedule
+	s = &Schedule{
+		c: c,
+		m: &sync.Mutex{},
+		o: o,
+	}
+
+	// Default options
+	if s.o.HistorySize <= 0 {
+		s.o.HistorySize = 10
+	}
+	if s.o.Overlap == "" {
+		s.o.Overlap = OverlapSkip
+	}
+	if s.o.Task.Name == "" {
+		s.o.Task.Name = "schedule"
+	}
+	if s.o.ComponentName == "" {
+		s.o.ComponentName = s.o.Task.Name
+	}
+
+	// Register component
+	st := newStatusComponent(StatusRunning)
+	cn := w.RegisterComponent(ComponentTypeSchedule, s.o.ComponentName, st)
+
+	// Execute in a task
+	s.t = w.NewTaskWithOptions(s.o.Task)
+	s.t.Do(func() {
+		// Make sure to wait for runs and update status
+		defer func() {
+			s.t.Wait()
+			st.set(StatusStopped)
+			w.UnregisterComponent(cn)
+		}()
+
+		// Loop
+		last := time.Now()
+		for {
+			// Get next run
+			if last = s.next(last); last.IsZero() {
+				astilog.Errorf("astiworker: no next run for schedule %s", s.o.ComponentName)
+				return
+			}
+			at := last
+			if s.o.Jitter > 0 {
+				at = at.Add(time.Duration(rand.Int63n(int64(s.o.Jitter))))
+			}
+			s.m.Lock()
+			s.nextRunAt = at
+			s.m.Unlock()
+
+			// Wait
+			select {
+			case <-time.After(time.Until(at)):
+			case <-s.t.Context().Done():
+				return
+			}
+
+			// Trigger
+			s.trigger()
+
+			// Make sure interval based schedules don't pile up if runs were delayed
+			if s.c == nil && time.Since(last) > s.o.Every {
+				last = time.Now()
+			}
+		}
+	})
+	return
+}
+
+func (s *Schedule) next(last time.Time) time.Time {
+	if s.c != nil {
+		return s.c.next(last)
+	}
+	return last.Add(s.o.Every)
+}
+
+func (s *Schedule) trigger() {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Previous run is still running
+	if s.running > 0 {
+		switch s.o.Overlap {
+		case OverlapQueue:
+			s.pending++
+			return
+		case OverlapSkip:
+			s.addRun(ScheduleRun{
+				Skipped:   true,
+				StartedAt: time.Now(),
+			})
+			return
+		}
+	}
+
+	// Run
+	s.running++
+	st := s.t.NewSubTask()
+	st.Do(s.run)
+}
+
+func (s *Schedule) run() {
+	for {
+		// Run
+		r := ScheduleRun{StartedAt: time.Now()}
+		r.Err = s.o.Handler(s.t.Context())
+		r.Duration = time.Since(r.StartedAt)
+		if r.Err != nil {
+			astilog.Error(errors.Wrapf(r.Err, "astiworker: running schedule %s failed", s.o.ComponentName))
+		}
+
+		// Lock
+		s.m.Lock()
+
+		// Add run
+		s.addRun(r)
+
+		// Run pending
+		if s.pending > 0 && s.t.Context().Err() == nil {
+			s.pending--
+			s.m.Unlock()
+			continue
+		}
+		s.running--
+		s.m.Unlock()
+		return
+	}
+}
+
+// Assumes the lock is held
+func (s *Schedule) addRun(r ScheduleRun) {
+	s.h = append(s.h, r)
+	if len(s.h) > s.o.HistorySize {
+		s.h = s.h[len(s.h)-s.o.HistorySize:]
+	}
+}
+
+// History returns the last runs, oldest first
+func (s *Schedule) History() []ScheduleRun {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]ScheduleRun(nil), s.h...)
+}
+
+// NextRunAt returns when the next run is due
+func (s *Schedule) NextRunAt() time.Time {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.nextRunAt
+}
+
+// Running returns the number of runs currently running
+func (s *Schedule) Running() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.running
+}
@@ -0,0 +1,59 @@
+package astiworker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_Schedule(t *testing.T) {
+	// Init
+	w := NewWorker()
+	var c int32
+	chanBlock := make(chan bool)
+
+	// Skip
+	s, err := w.Schedule(ScheduleOptions{
+		Every: 5 * time.Millisecond,
+		Handler: func(ctx context.Context) error {
+			if atomic.AddInt32(&c, 1) == 1 {
+				<-chanBlock
+			}
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&c))
+	assert.Equal(t, 1, s.Running())
+	h := s.History()
+	assert.NotEmpty(t, h)
+	assert.True(t, h[0].Skipped)
+	close(chanBlock)
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, atomic.LoadInt32(&c) > 1)
+
+	// Stop
+	w.Stop()
+	assert.NoError(t, w.Wait())
+	n := atomic.LoadInt32(&c)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, n, atomic.LoadInt32(&c))
+}
+
+func TestWorker_ScheduleInvalidOptions(t *testing.T) {
+	w := NewWorker()
+	defer w.Stop()
+	s, err := w.Schedule(ScheduleOptions{Every: time.Second})
+	assert.Error(t, err)
+	assert.Nil(t, s)
+	s, err = w.Schedule(ScheduleOptions{Handler: func(ctx context.Context) error { return nil }})
+	assert.Error(t, err)
+	assert.Nil(t, s)
+	s, err = w.Schedule(ScheduleOptions{Cron: "invalid", Handler: func(ctx context.Context) error { return nil }})
+	assert.Error(t, err)
+	assert.Nil(t, s)
+}
@@ -2,38 +2,74 @@ package astiworker
 
 import (
 	"context"
+	"net"
 	"net/http"
 
 	"github.com/asticode/go-astilog"
 	"github.com/pkg/errors"
 )
 
+// ServeOptions represents serve options
+type ServeOptions struct {
+	Addr    string
+	Handler http.Handler
+	// Task name defaults to the address
+	Task TaskOptions
+}
+
 // Serve spawns a server
 func (w *Worker) Serve(addr string, h http.Handler) {
+	w.ServeWithOptions(ServeOptions{
+		Addr:    addr,
+		Handler: h,
+	})
+}
+
+// ServeWithOptions spawns a server with options
+func (w *Worker) ServeWithOptions(o ServeOptions) {
 	// Create server
-	s := &http.Server{Addr: addr, Handler: h}
+	addr := o.Addr
+	s := &http.Server{Addr: addr, Handler: o.Handler}
+
+	// Register component
+	c := newStatusComponent(StatusStarting)
+	n := w.RegisterComponent(ComponentTypeServer, addr, c)
 
 	// Execute in a task
-	w.NewTask().Do(func() {
+	if o.Task.Name == "" {
+		o.Task.Name = addr
+	}
+	t := w.NewTaskWithOptions(o.Task)
+	t.Do(func() {
 		// Log
 		astilog.Infof("astiworker: serving on %s", addr)
 
 		// Serve
-		var chanDone = make(chan error)
+		var chanDone = make(chan error, 1)
 		go func() {
-			if err := s.ListenAndServe(); err != nil {
+			// Listen
+			l, err := net.Listen("tcp", addr)
+			if err != nil {
+				chanDone <- err
+				return
+			}
+			c.set(StatusRunning)
+
+			// Serve
+			if err := s.Serve(l); err != nil {
 				chanDone <- err
 			}
 		}()
 
 		// Wait for context or chanDone to be done
 		select {
-		case <-w.ctx.Done():
-			if w.ctx.Err() != context.Canceled {
-				astilog.Error(errors.Wrap(w.ctx.Err(), "astiworker: context error"))
+		case <-t.Context().Done():
+			if t.Context().Err() != context.Canceled {
+				astilog.Error(errors.Wrap(t.Context().Err(), "astiworker: context error"))
 			}
 		case err := <-chanDone:
 			if err != nil {
+				c.set(StatusCrashed)
 				astilog.Error(errors.Wrap(err, "astiworker: serving failed"))
 			}
 		}
@@ -43,5 +79,9 @@ func (w *Worker) Serve(addr string, h http.Handler) {
 		if err := s.Shutdown(context.Background()); err != nil {
 			astilog.Error(errors.Wrapf(err, "astiworker: shutting down server on %s failed", addr))
 		}
+		if c.Status() != StatusCrashed {
+			c.set(StatusStopped)
+			w.UnregisterComponent(n)
+		}
 	})
 }
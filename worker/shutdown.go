@@ -0,0 +1,157 @@
+package astiworker
+
+import (
+	"context"
+	"time"
+
+	"github.com/asticode/go-astilog"
+)
+
+// ShutdownReport represents a shutdown report
+type ShutdownReport struct {
+	Duration   time.Duration
+	startedAt  time.Time
+	Unfinished []UnfinishedTask
+}
+
+// UnfinishedTask represents a task that failed to finish in time during shutdown
+type UnfinishedTask struct {
+	Name    string
+	Phase   int
+	Timeout time.Duration
+}
+
+// ShutdownReport returns the shutdown report
+// It is only complete once Wait has returned
+func (w *Worker) ShutdownReport() (r ShutdownReport) {
+	w.mt.Lock()
+	defer w.mt.Unlock()
+	r = w.report
+	r.Unfinished = append([]UnfinishedTask(nil), w.report.Unfinished...)
+	return
+}
+
+// effectivePhases returns the shutdown phase of tasks that have not been cancelled yet, taking dependencies into
+// account: a task is always shut down in a later phase than the tasks depending on it
+// Assumes the lock is held
+func (w *Worker) effectivePhases() (ps map[*Task]int) {
+	// Index tasks
+	ps = make(map[*Task]int)
+	ns := make(map[string][]*Task)
+	for t := range w.ts {
+		if !t.cancelled {
+			ps[t] = t.phase
+			ns[t.name] = append(ns[t.name], t)
+		}
+	}
+
+	// Propagate phases through dependencies
+	for idx := 0; idx <= len(ps); idx++ {
+		var changed bool
+		for t := range ps {
+			for _, n := range t.deps {
+				for _, d := range ns[n] {
+					if ps[d] < ps[t]+1 {
+						ps[d] = ps[t] + 1
+						changed = true
+					}
+				}
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+	astilog.Error("astiworker: cyclic task dependencies detected, shutdown order may be wrong")
+	return
+}
+
+// nextShutdownPhase cancels the tasks of the next shutdown phase and returns them
+func (w *Worker) nextShutdownPhase() (p int, ts []*Task, ok bool) {
+	// Lock
+	w.mt.Lock()
+	defer w.mt.Unlock()
+
+	// Get next phase
+	ps := w.effectivePhases()
+	if ok = w.ctx.Err() == nil; ok {
+		p = 0
+	}
+	for _, v := range ps {
+		if !ok || v < p {
+			p = v
+			ok = true
+		}
+	}
+	if !ok {
+		return
+	}
+	w.phase = &p
+
+	// Cancel worker context
+	if p >= 0 {
+		w.cancel()
+	}
+
+	// Cancel tasks
+	for t, v := range ps {
+		if v <= p {
+			t.cancelled = true
+			t.cancel()
+			ts = append(ts, t)
+		}
+	}
+	return
+}
+
+func (w *Worker) shutdown(p int, ts []*Task, ok bool) {
+	// Make sure to close the done channel
+	defer func() {
+		w.mt.Lock()
+		w.report.Duration = time.Since(w.report.startedAt)
+		w.mt.Unlock()
+		close(w.chanDone)
+	}()
+
+	// Loop through phases
+	for ; ok; p, ts, ok = w.nextShutdownPhase() {
+		// Get timeout
+		timeout := w.o.ShutdownPhaseTimeout
+		if v, ok := w.o.ShutdownPhaseTimeouts[p]; ok {
+			timeout = v
+		}
+
+		// Create context
+		ctx, cancel := context.WithCancel(context.Background())
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		}
+
+		// Wait for tasks
+		astilog.Debugf("astiworker: shutting down phase %d with %d task(s)", p, len(ts))
+		for _, t := range ts {
+			select {
+			case <-t.done:
+			case <-ctx.Done():
+			}
+
+			// Task has finished
+			select {
+			case <-t.done:
+				continue
+			default:
+			}
+
+			// Task failed to finish in time
+			astilog.Errorf("astiworker: task %s didn't finish within %s during shutdown phase %d", t.name, timeout, p)
+			w.mt.Lock()
+			w.report.Unfinished = append(w.report.Unfinished, UnfinishedTask{
+				Name:    t.name,
+				Phase:   p,
+				Timeout: timeout,
+			})
+			w.mt.Unlock()
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,53 @@
+package astiworker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_Shutdown(t *testing.T) {
+	// Init
+	w := NewWorkerWithOptions(WorkerOptions{ShutdownPhaseTimeouts: map[int]time.Duration{2: 10 * time.Millisecond}})
+	var m sync.Mutex
+	var o []string
+	chanUnblock := make(chan bool)
+	defer close(chanUnblock)
+	add := func(name string) {
+		m.Lock()
+		defer m.Unlock()
+		o = append(o, name)
+	}
+	do := func(t *Task, block bool) {
+		t.Do(func() {
+			<-t.Context().Done()
+			if block {
+				<-chanUnblock
+			}
+			add(t.Name())
+		})
+	}
+
+	// Tasks
+	do(w.NewTaskWithOptions(TaskOptions{Name: "consumer"}), false)
+	do(w.NewTaskWithOptions(TaskOptions{DependsOn: []string{"consumer"}, Name: "server"}), false)
+	do(w.NewTaskWithOptions(TaskOptions{Name: "first", Phase: -1}), false)
+	do(w.NewTaskWithOptions(TaskOptions{Name: "hanging", Phase: 2}), true)
+	t1 := w.NewTask()
+	t1.Do(func() {
+		<-w.Context().Done()
+		add("worker")
+	})
+
+	// Stop
+	w.Stop()
+	w.Wait()
+	m.Lock()
+	assert.Equal(t, "first", o[0])
+	assert.ElementsMatch(t, []string{"server", "worker"}, o[1:3])
+	assert.Equal(t, []string{"consumer"}, o[3:])
+	m.Unlock()
+	assert.Equal(t, []UnfinishedTask{{Name: "hanging", Phase: 2, Timeout: 10 * time.Millisecond}}, w.ShutdownReport().Unfinished)
+}
@@ -0,0 +1,146 @@
+package astiworker
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/asticode/go-astilog"
+)
+
+// exit allows testing functions using it
+var exit = os.Exit
+
+// SignalHandler represents a func that can handle a signal
+type SignalHandler func(s os.Signal)
+
+// Default signals
+var (
+	DefaultReloadSignals = []os.Signal{syscall.SIGHUP}
+	DefaultTermSignals   = []os.Signal{syscall.SIGABRT, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM}
+)
+
+func containsSignal(ss []os.Signal, s os.Signal) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TermSignalHandler returns a SignalHandler that is executed only on a default term signal
+// It is meant to be used with HandleSignals. Use SignalOptions.OnTerm when using custom term signals.
+func TermSignalHandler(f func()) SignalHandler {
+	return func(s os.Signal) {
+		if containsSignal(DefaultTermSignals, s) {
+			f()
+		}
+	}
+}
+
+// SignalOptions represents signal options
+type SignalOptions struct {
+	// ExitCode is used when the process is exited forcefully. Defaults to 1.
+	ExitCode int
+	// If ForceExitOnSecondSignal is true, a second term signal exits the process immediately
+	ForceExitOnSecondSignal bool
+	// Handlers are executed on every signal listened to
+	Handlers []SignalHandler
+	// If HardDeadline > 0, the process exits once HardDeadline has elapsed after the first term signal, even if tasks
+	// are still running
+	HardDeadline time.Duration
+	OnReload     func()
+	// OnTerm is executed on every term signal, before the worker is stopped
+	OnTerm func()
+	// ReloadSignals execute OnReload. Defaults to DefaultReloadSignals if OnReload is set.
+	ReloadSignals []os.Signal
+	// Signals are the signals listened to in addition to term and reload signals
+	Signals []os.Signal
+	// TermSignals stop the worker. Defaults to DefaultTermSignals.
+	TermSignals []os.Signal
+}
+
+// HandleSignals handles term signals and executes handlers on them
+func (w *Worker) HandleSignals(hs ...SignalHandler) {
+	w.HandleSignalsWithOptions(SignalOptions{Handlers: hs})
+}
+
+// HandleSignalsWithOptions handles signals with options
+// Signals are listened to until the worker is done waiting
+func (w *Worker) HandleSignalsWithOptions(o SignalOptions) {
+	// Default options
+	if len(o.TermSignals) == 0 {
+		o.TermSignals = DefaultTermSignals
+	}
+	if o.OnReload != nil && len(o.ReloadSignals) == 0 {
+		o.ReloadSignals = DefaultReloadSignals
+	}
+	if o.ExitCode == 0 {
+		o.ExitCode = 1
+	}
+
+	// Notify
+	ch := make(chan os.Signal, 1)
+	ss := append(append(append([]os.Signal{}, o.TermSignals...), o.ReloadSignals...), o.Signals...)
+	signal.Notify(ch, ss...)
+
+	// Listen
+	go func() {
+		// Make sure to stop listening
+		defer signal.Stop(ch)
+
+		// Loop
+		var deadline <-chan time.Time
+		var termSignals int
+		for {
+			select {
+			case s := <-ch:
+				// Log
+				astilog.Debugf("astiworker: received signal %s", s)
+
+				// Loop through handlers
+				for _, h := range o.Handlers {
+					h(s)
+				}
+
+				// Reload
+				if containsSignal(o.ReloadSignals, s) {
+					astilog.Info("astiworker: reloading")
+					o.OnReload()
+				}
+
+				// Term
+				if containsSignal(o.TermSignals, s) {
+					// Custom
+					if o.OnTerm != nil {
+						o.OnTerm()
+					}
+
+					// Force exit
+					termSignals++
+					if termSignals > 1 && o.ForceExitOnSecondSignal {
+						astilog.Errorf("astiworker: received second term signal %s, exiting", s)
+						exit(o.ExitCode)
+						return
+					}
+
+					// Stop
+					if termSignals == 1 {
+						if o.HardDeadline > 0 {
+							deadline = time.After(o.HardDeadline)
+						}
+						w.Stop()
+					}
+				}
+			case <-deadline:
+				astilog.Errorf("astiworker: worker didn't stop within %s, exiting", o.HardDeadline)
+				exit(o.ExitCode)
+				return
+			case <-w.chanDone:
+				return
+			}
+		}
+	}()
+}
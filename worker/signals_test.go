@@ -0,0 +1,60 @@
+package astiworker
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker_HandleSignalsWithOptions(t *testing.T) {
+	// Mock exit
+	chanExit := make(chan int, 1)
+	defer func(f func(int)) { exit = f }(exit)
+	exit = func(code int) { chanExit <- code }
+
+	// Init
+	w := NewWorker()
+	chanReload := make(chan bool, 1)
+	chanTerm := make(chan bool, 2)
+	w.HandleSignalsWithOptions(SignalOptions{
+		ExitCode:                2,
+		ForceExitOnSecondSignal: true,
+		OnReload:                func() { chanReload <- true },
+		OnTerm:                  func() { chanTerm <- true },
+		TermSignals:             []os.Signal{syscall.SIGUSR1},
+	})
+	hanging := w.NewTask()
+	defer hanging.Done()
+	p, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+
+	// Reload
+	assert.NoError(t, p.Signal(syscall.SIGHUP))
+	select {
+	case <-chanReload:
+	case <-time.After(time.Second):
+		t.Fatal("reload wasn't executed")
+	}
+	assert.NoError(t, w.Context().Err())
+
+	// First term signal
+	assert.NoError(t, p.Signal(syscall.SIGUSR1))
+	select {
+	case <-w.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("worker wasn't stopped")
+	}
+
+	// Second term signal
+	assert.NoError(t, p.Signal(syscall.SIGUSR1))
+	select {
+	case c := <-chanExit:
+		assert.Equal(t, 2, c)
+	case <-time.After(time.Second):
+		t.Fatal("process didn't exit")
+	}
+	assert.Len(t, chanTerm, 2)
+}
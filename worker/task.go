@@ -1,21 +1,48 @@
 package astiworker
 
 import (
+	"context"
 	"sync"
 )
 
 // Task represents a task
 type Task struct {
-	od, ow  sync.Once
-	wg, pwg *sync.WaitGroup
+	cancel    context.CancelFunc
+	cancelled bool
+	ctx       context.Context
+	deps      []string
+	done      chan bool
+	fatal     bool
+	name      string
+	od, ow    sync.Once
+	onDone    func()
+	onError   func(err error)
+	phase     int
+	wg, pwg   *sync.WaitGroup
 }
 
-func newTask(parentWg *sync.WaitGroup) (t *Task) {
+// TaskOptions represents task options
+type TaskOptions struct {
+	// DependsOn contains the names of the tasks this task depends on. They will be shut down after this task.
+	DependsOn []string
+	// If Fatal is true, the worker is stopped as soon as the task returns an error
+	Fatal bool
+	Name  string
+	// Tasks are shut down by ascending phase. The default phase is 0 which is also the phase during which the worker's
+	// context is cancelled.
+	Phase int
+}
+
+func newTask(ctx context.Context, parentWg *sync.WaitGroup) (t *Task) {
 	t = &Task{
-		wg:  &sync.WaitGroup{},
-		pwg: parentWg,
+		done: make(chan bool),
+		wg:   &sync.WaitGroup{},
+		pwg:  parentWg,
+	}
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	if t.pwg != nil {
+		t.pwg.Add(1)
 	}
-	t.pwg.Add(1)
 	return
 }
 
@@ -23,8 +50,20 @@ func newTask(parentWg *sync.WaitGroup) (t *Task) {
 type TaskFunc func() *Task
 
 // NewSubTask creates a new sub task
-func (t *Task) NewSubTask() *Task {
-	return newTask(t.wg)
+func (t *Task) NewSubTask() (st *Task) {
+	st = newTask(t.ctx, t.wg)
+	st.onError = t.onError
+	return
+}
+
+// Context returns the task's context which is cancelled when the task should shut down
+func (t *Task) Context() context.Context {
+	return t.ctx
+}
+
+// Name returns the task's name
+func (t *Task) Name() string {
+	return t.name
 }
 
 // Do executes the task
@@ -38,10 +77,31 @@ func (t *Task) Do(f func()) {
 	}()
 }
 
+// DoWithError executes the task and collects the error it returns, if any
+// Errors are returned by Worker.Wait
+func (t *Task) DoWithError(f func() error) {
+	go func() {
+		// Make sure to mark the task as done
+		defer t.Done()
+
+		// Custom
+		if err := f(); err != nil && t.onError != nil {
+			t.onError(err)
+		}
+	}()
+}
+
 // Done indicates the task is done
 func (t *Task) Done() {
 	t.od.Do(func() {
-		t.pwg.Done()
+		if t.pwg != nil {
+			t.pwg.Done()
+		}
+		close(t.done)
+		t.cancel()
+		if t.onDone != nil {
+			t.onDone()
+		}
 	})
 }
 
@@ -0,0 +1,37 @@
+package astiworker
+
+import (
+	"errors"
+	"testing"
+
+	astierror "github.com/asticode/go-astitools/error"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTask_DoWithError(t *testing.T) {
+	// Init
+	w := NewWorker()
+
+	// Non fatal
+	t1 := w.NewTaskWithOptions(TaskOptions{Name: "t1"})
+	t1.NewSubTask().DoWithError(func() error { return errors.New("1") })
+	t1.DoWithError(func() error {
+		t1.Wait()
+		return nil
+	})
+
+	// Fatal
+	t2 := w.NewTaskWithOptions(TaskOptions{Fatal: true, Name: "t2"})
+	t2.DoWithError(func() error {
+		t1.Wait()
+		return errors.New("2")
+	})
+
+	// Wait
+	err := w.Wait()
+	assert.Error(t, err)
+	m, ok := err.(astierror.Multiple)
+	assert.True(t, ok)
+	assert.Len(t, m, 2)
+	assert.Equal(t, "astiworker: task t1 failed: 1 | astiworker: task t2 failed: 2", err.Error())
+}
@@ -2,100 +2,158 @@ package astiworker
 
 import (
 	"context"
-	"os"
-	"os/signal"
+	"strconv"
 	"sync"
-	"syscall"
+	"time"
 
 	"github.com/asticode/go-astilog"
+	astierror "github.com/asticode/go-astitools/error"
+	"github.com/pkg/errors"
 )
 
 // Worker represents an object capable of blocking, handling signals and stopping
 type Worker struct {
-	cancel context.CancelFunc
-	ctx    context.Context
-	os, ow sync.Once
-	wg     *sync.WaitGroup
+	cancel   context.CancelFunc
+	chanDone chan bool
+	cs       map[string]component
+	ctx      context.Context
+	errs     []error
+	mc       *sync.Mutex // Locks cs
+	me       *sync.Mutex // Locks errs
+	mt       *sync.Mutex // Locks phase, report, stopping and ts
+	o        WorkerOptions
+	os, ow   sync.Once
+	phase    *int
+	report   ShutdownReport
+	stopping bool
+	taskID   int
+	ts       map[*Task]bool
 }
 
-// NewWorker builds a new worker
-func NewWorker() (w *Worker) {
-	astilog.Info("astiworker: starting worker...")
-	w = &Worker{wg: &sync.WaitGroup{}}
-	w.ctx, w.cancel = context.WithCancel(context.Background())
-	w.wg.Add(1)
-	return
+// WorkerOptions represents worker options
+type WorkerOptions struct {
+	// ShutdownPhaseTimeout is the max duration the worker waits for the tasks of a phase to finish during shutdown.
+	// ShutdownPhaseTimeouts can be used to override it for specific phases. 0 means no timeout.
+	ShutdownPhaseTimeout  time.Duration
+	ShutdownPhaseTimeouts map[int]time.Duration
 }
 
-// SignalHandler represents a func that can handle a signal
-type SignalHandler func(s os.Signal)
-
-func isTermSignal(s os.Signal) bool {
-	return s == syscall.SIGABRT || s == syscall.SIGKILL || s == syscall.SIGINT || s == syscall.SIGQUIT || s == syscall.SIGTERM
+// NewWorker builds a new worker
+func NewWorker() *Worker {
+	return NewWorkerWithOptions(WorkerOptions{})
 }
 
-// TermSignalHandler returns a SignalHandler that is executed only on a term signal
-func TermSignalHandler(f func()) SignalHandler {
-	return func(s os.Signal) {
-		if isTermSignal(s) {
-			f()
-		}
+// NewWorkerWithOptions builds a new worker with options
+func NewWorkerWithOptions(o WorkerOptions) (w *Worker) {
+	astilog.Info("astiworker: starting worker...")
+	w = &Worker{
+		chanDone: make(chan bool),
+		cs:       make(map[string]component),
+		mc:       &sync.Mutex{},
+		me:       &sync.Mutex{},
+		mt:       &sync.Mutex{},
+		o:        o,
+		ts:       make(map[*Task]bool),
 	}
-}
-
-// HandleSignals handles signals
-func (w *Worker) HandleSignals(hs ...SignalHandler) {
-	// Add default handler
-	hs = append([]SignalHandler{TermSignalHandler(w.Stop)}, hs...)
-
-	// Notify
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch)
-
-	// Execute in a task
-	w.NewTask().Do(func() {
-		for {
-			select {
-			case s := <-ch:
-				// Log
-				astilog.Debugf("astiworker: received signal %s", s)
-
-				// Loop through handlers
-				for _, h := range hs {
-					h(s)
-				}
-
-				// Return
-				if isTermSignal(s) {
-					return
-				}
-			case <-w.Context().Done():
-				return
-			}
-		}
-	})
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	return
 }
 
 // Stop stops the Worker
+// Tasks are shut down phase by phase and the worker's context is cancelled during phase 0. Stop only initiates the
+// shutdown, use Wait to wait for it to be finished.
 func (w *Worker) Stop() {
 	w.os.Do(func() {
 		astilog.Info("astiworker: stopping worker...")
-		w.cancel()
-		w.wg.Done()
+		w.mt.Lock()
+		w.stopping = true
+		w.report.startedAt = time.Now()
+		w.mt.Unlock()
+		p, ts, ok := w.nextShutdownPhase()
+		go w.shutdown(p, ts, ok)
 	})
 }
 
+func (w *Worker) isStopping() bool {
+	w.mt.Lock()
+	defer w.mt.Unlock()
+	return w.stopping
+}
+
 // Wait is a blocking pattern
-func (w *Worker) Wait() {
+// It returns once the worker has been stopped and all tasks have finished or timed out. If tasks have returned errors,
+// they are returned as an astierror.Multiple.
+func (w *Worker) Wait() error {
 	w.ow.Do(func() {
 		astilog.Info("astiworker: worker is now waiting...")
-		w.wg.Wait()
+		<-w.chanDone
 	})
+	return w.Err()
+}
+
+// Err returns the errors returned by tasks so far as an astierror.Multiple, or nil if there are none
+func (w *Worker) Err() error {
+	w.me.Lock()
+	defer w.me.Unlock()
+	if len(w.errs) == 0 {
+		return nil
+	}
+	return astierror.NewMultiple(append([]error(nil), w.errs...))
+}
+
+func (w *Worker) addTaskError(t *Task, err error) {
+	// Add error
+	err = errors.Wrapf(err, "astiworker: task %s failed", t.name)
+	astilog.Error(err)
+	w.me.Lock()
+	w.errs = append(w.errs, err)
+	w.me.Unlock()
+
+	// Stop
+	if t.fatal {
+		w.Stop()
+	}
 }
 
 // NewTask creates a new task
 func (w *Worker) NewTask() *Task {
-	return newTask(w.wg)
+	return w.NewTaskWithOptions(TaskOptions{})
+}
+
+// NewTaskWithOptions creates a new task with options
+func (w *Worker) NewTaskWithOptions(o TaskOptions) (t *Task) {
+	// Create task
+	t = newTask(context.Background(), nil)
+	t.deps = o.DependsOn
+	t.fatal = o.Fatal
+	t.onError = func(err error) { w.addTaskError(t, err) }
+	t.phase = o.Phase
+
+	// Lock
+	w.mt.Lock()
+	defer w.mt.Unlock()
+
+	// Default name
+	w.taskID++
+	t.name = o.Name
+	if t.name == "" {
+		t.name = "task #" + strconv.Itoa(w.taskID)
+	}
+
+	// Add task
+	w.ts[t] = true
+	t.onDone = func() {
+		w.mt.Lock()
+		defer w.mt.Unlock()
+		delete(w.ts, t)
+	}
+
+	// Shutdown has already gone past the task's phase
+	if w.phase != nil && t.phase <= *w.phase {
+		t.cancelled = true
+		t.cancel()
+	}
+	return
 }
 
 // Context returns the worker's context